@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// Codec converts cache values to and from the representation stored by a cache.
+type Codec interface {
+	Marshal(value any) ([]byte, error)
+	Unmarshal(data []byte, value any) error
+}
+
+// EntryCodec is implemented by codecs that bind encoded values to the entry they are stored under,
+// so a value copied to another entry is rejected. Caches persisting values outside the process pass
+// the name of the entry, which includes the cache key and namespace.
+type EntryCodec interface {
+	Codec
+
+	MarshalEntry(entry string, value any) ([]byte, error)
+	UnmarshalEntry(entry string, data []byte, value any) error
+}
+
+type jsonCodec struct {
+}
+
+func NewJSONCodec() Codec {
+	return &jsonCodec{}
+}
+
+func (c *jsonCodec) Marshal(value any) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (c *jsonCodec) Unmarshal(data []byte, value any) error {
+	return json.Unmarshal(data, value)
+}
+
+type gobCodec struct {
+}
+
+// NewGobCodec creates a codec based on encoding/gob, all concrete types stored behind
+// interface values have to be registered with gob.Register beforehand.
+func NewGobCodec() Codec {
+	return &gobCodec{}
+}
+
+func (c *gobCodec) Marshal(value any) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(value); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (c *gobCodec) Unmarshal(data []byte, value any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(value)
+}
+
+type rawCodec struct {
+}
+
+// NewRawCodec creates a codec that stores []byte values as they are, values of any other type are rejected.
+func NewRawCodec() Codec {
+	return &rawCodec{}
+}
+
+func (c *rawCodec) Marshal(value any) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return bytes.Clone(v), nil
+	case *[]byte:
+		return bytes.Clone(*v), nil
+	default:
+		return nil, NewErrUnsupportedValueType(value)
+	}
+}
+
+func (c *rawCodec) Unmarshal(data []byte, value any) error {
+	target, ok := value.(*[]byte)
+	if !ok {
+		return NewErrUnsupportedValueType(value)
+	}
+	*target = bytes.Clone(data)
+	return nil
+}
+
+func decode[Entity any](codec Codec, data []byte) (*Entity, error) {
+	var value Entity
+	if err := codec.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+// marshalEntry encodes value for entry, codecs not implementing EntryCodec ignore the entry
+func marshalEntry(codec Codec, entry string, value any) ([]byte, error) {
+	if entryCodec, ok := codec.(EntryCodec); ok {
+		return entryCodec.MarshalEntry(entry, value)
+	}
+	return codec.Marshal(value)
+}
+
+func unmarshalEntry(codec Codec, entry string, data []byte, value any) error {
+	if entryCodec, ok := codec.(EntryCodec); ok {
+		return entryCodec.UnmarshalEntry(entry, data, value)
+	}
+	return codec.Unmarshal(data, value)
+}
+
+func decodeEntry[Entity any](codec Codec, entry string, data []byte) (*Entity, error) {
+	var value Entity
+	if err := unmarshalEntry(codec, entry, data, &value); err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+// valuesEqual compares values by their JSON representation, independent of the codec of a cache,
+// as codecs like gob or encrypting codecs do not produce a stable encoding
+func valuesEqual(a any, b any) bool {
+	jsonBytesA, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	jsonBytesB, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(jsonBytesA, jsonBytesB)
+}
@@ -0,0 +1,165 @@
+package cache
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	testCases := []struct {
+		name  string
+		codec Codec
+	}{
+		{name: "json", codec: NewJSONCodec()},
+		{name: "gob", codec: NewGobCodec()},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e1 := demoEntity{
+				Value1: "value-for-v1",
+				Value2: p("value-for-v2"),
+				Value3: p(map[string]string{"mapkey1": "mapvalue1"}),
+			}
+			data, err := tc.codec.Marshal(e1)
+			require.Nil(t, err)
+
+			var got demoEntity
+			err = tc.codec.Unmarshal(data, &got)
+			require.Nil(t, err)
+			require.EqualValues(t, e1, got)
+		})
+	}
+}
+
+func TestRawCodec(t *testing.T) {
+	codec := NewRawCodec()
+
+	value := []byte("raw-value")
+	data, err := codec.Marshal(value)
+	require.Nil(t, err)
+	value[0] = 'X'
+	require.Equal(t, []byte("raw-value"), data)
+
+	var got []byte
+	err = codec.Unmarshal(data, &got)
+	require.Nil(t, err)
+	require.Equal(t, []byte("raw-value"), got)
+
+	_, err = codec.Marshal("not-a-byte-slice")
+	require.ErrorAs(t, err, &ErrUnsupportedValueType{})
+}
+
+func TestMemoryCacheWithCodec(t *testing.T) {
+	ctx := context.TODO()
+	cut := NewMemoryCacheWithConfig[time.Time](ctx, &MemoryCacheConfig{
+		Codec: NewGobCodec(),
+	})
+
+	now := time.Now()
+	err := cut.Set(ctx, "now", now, 0)
+	require.Nil(t, err)
+
+	got, err := cut.Get(ctx, "now")
+	require.Nil(t, err)
+	require.NotNil(t, got)
+	require.True(t, now.Equal(*got))
+}
+
+func TestCompressingCodec(t *testing.T) {
+	codec := NewCompressingCodec(NewJSONCodec(), 64)
+
+	small := demoEntity{Value1: "small"}
+	data, err := codec.Marshal(small)
+	require.Nil(t, err)
+	require.Equal(t, compressionMarkerNone, data[0])
+
+	large := demoEntity{Value1: strings.Repeat("large", 100)}
+	data, err = codec.Marshal(large)
+	require.Nil(t, err)
+	require.Equal(t, compressionMarkerGzip, data[0])
+	require.Less(t, len(data), len(large.Value1))
+
+	var got demoEntity
+	err = codec.Unmarshal(data, &got)
+	require.Nil(t, err)
+	require.EqualValues(t, large, got)
+
+	legacy, err := NewJSONCodec().Marshal(small)
+	require.Nil(t, err)
+	got = demoEntity{}
+	err = codec.Unmarshal(legacy, &got)
+	require.Nil(t, err)
+	require.EqualValues(t, small, got)
+}
+
+func TestEncryptingCodecKeyRotation(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 32)
+	value := demoEntity{Value1: "secret"}
+
+	oldCodec := NewEncryptingCodec(NewJSONCodec(), NewStaticKeyProvider("v1", map[string][]byte{"v1": oldKey}))
+	data, err := oldCodec.Marshal(value)
+	require.Nil(t, err)
+	require.NotContains(t, string(data), "secret")
+
+	rotated := NewEncryptingCodec(NewJSONCodec(), NewStaticKeyProvider("v2", map[string][]byte{"v1": oldKey, "v2": newKey}))
+	var got demoEntity
+	require.Nil(t, rotated.Unmarshal(data, &got))
+	require.EqualValues(t, value, got)
+
+	data, err = rotated.Marshal(value)
+	require.Nil(t, err)
+	err = oldCodec.Unmarshal(data, &got)
+	require.ErrorAs(t, err, &ErrUnknownEncryptionKey{})
+
+	// tampering is detected
+	data[len(data)-1] ^= 0xff
+	require.NotNil(t, rotated.Unmarshal(data, &got))
+
+	plain, err := NewJSONCodec().Marshal(value)
+	require.Nil(t, err)
+	require.ErrorAs(t, rotated.Unmarshal(plain, &got), &ErrMalformedEncryptedValue{})
+
+	longKeyID := strings.Repeat("k", 256)
+	_, err = NewEncryptingCodec(NewJSONCodec(), NewStaticKeyProvider(longKeyID, map[string][]byte{longKeyID: newKey})).Marshal(value)
+	require.ErrorAs(t, err, &ErrEncryptionKeyIDTooLong{})
+}
+
+func TestEncryptingCodecBindsValuesToEntries(t *testing.T) {
+	ctx := context.TODO()
+	codec := NewEncryptingCodec(NewJSONCodec(), NewStaticKeyProvider("v1", map[string][]byte{"v1": bytes.Repeat([]byte{1}, 32)}))
+	value := demoEntity{Value1: "secret"}
+
+	data, err := marshalEntry(codec, "cache|a", value)
+	require.Nil(t, err)
+	got, err := decodeEntry[demoEntity](codec, "cache|a", data)
+	require.Nil(t, err)
+	require.EqualValues(t, value, *got)
+	_, err = decodeEntry[demoEntity](codec, "cache|b", data)
+	require.NotNil(t, err)
+
+	// a ciphertext copied to the file of another key fails to decrypt
+	cut, err := NewDiskCacheWithConfig[demoEntity](ctx, t.TempDir(), &DiskCacheConfig{Codec: codec})
+	require.Nil(t, err)
+	disk := cut.(*diskCache[demoEntity])
+	require.Nil(t, cut.Set(ctx, "a", value, 0))
+	require.Nil(t, cut.Set(ctx, "b", demoEntity{Value1: "other"}, 0))
+	content, err := os.ReadFile(disk.entryPath("a"))
+	require.Nil(t, err)
+	require.Nil(t, os.WriteFile(disk.entryPath("b"), content, 0o600))
+	_, err = cut.Get(ctx, "b")
+	require.NotNil(t, err)
+
+	// as well as one copied to another namespace
+	namespaced := cut.WithNamespace("ns").(*diskCache[demoEntity])
+	require.Nil(t, namespaced.Set(ctx, "a", demoEntity{Value1: "other"}, 0))
+	require.Nil(t, os.WriteFile(namespaced.entryPath("a"), content, 0o600))
+	_, err = namespaced.Get(ctx, "a")
+	require.NotNil(t, err)
+}
@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// The marker byte precedes every value written by a compressing codec. Neither marker is a valid
+// first byte of a JSON document, so values written by a JSON codec before compression was enabled
+// remain readable. Values of other codecs might start with a marker, so enabling compression on top
+// of them requires the cache to be cleared.
+const (
+	compressionMarkerNone byte = 0x00
+	compressionMarkerGzip byte = 0x01
+)
+
+type compressingCodec struct {
+	codec     Codec
+	threshold int
+}
+
+// NewCompressingCodec wraps codec and gzip-compresses all encoded values larger than threshold bytes.
+func NewCompressingCodec(codec Codec, threshold int) Codec {
+	return &compressingCodec{
+		codec:     codec,
+		threshold: threshold,
+	}
+}
+
+func (c *compressingCodec) Marshal(value any) ([]byte, error) {
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) <= c.threshold {
+		return append([]byte{compressionMarkerNone}, data...), nil
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteByte(compressionMarkerGzip)
+	writer := gzip.NewWriter(&buffer)
+	if _, err = writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (c *compressingCodec) Unmarshal(data []byte, value any) error {
+	if len(data) == 0 {
+		return c.codec.Unmarshal(data, value)
+	}
+	switch data[0] {
+	case compressionMarkerNone:
+		return c.codec.Unmarshal(data[1:], value)
+	case compressionMarkerGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(data[1:]))
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		decompressed, err := io.ReadAll(reader)
+		if err != nil {
+			return err
+		}
+		return c.codec.Unmarshal(decompressed, value)
+	default:
+		// value was written without compression codec
+		return c.codec.Unmarshal(data, value)
+	}
+}
@@ -0,0 +1,791 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"iter"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+)
+
+const (
+	diskEntrySuffix     = ".entry"
+	diskTempPrefix      = ".tmp-"
+	diskLockFileName    = ".lock"
+	diskNamespacePrefix = "ns."
+)
+
+type diskCache[Entity any] struct {
+	// mu serialises the operations of this process, lock those of other processes
+	mu       sync.Mutex
+	lock     *fileLock
+	openOnce sync.Once
+	openErr  error
+	closed   atomic.Bool
+	// done is closed by Close to stop the janitor
+	done chan struct{}
+	dir  string
+	// namespacePath lists the escaped namespaces of a view, each preceded by a slash
+	namespacePath string
+	codec         Codec
+	config        DiskCacheConfig
+
+	childrenMu sync.Mutex
+	children   map[string]*diskCache[Entity]
+}
+
+// diskEntry is the content of an entry file, the key is stored as file names are derived from its hash
+type diskEntry struct {
+	Key       string    `json:"key"`
+	ExpiresAt time.Time `json:"expiresAt,omitzero"`
+	Value     []byte    `json:"value"`
+}
+
+type DiskCacheConfig struct {
+	// JanitorInterval is the period in which expired entries are removed in the background,
+	// a non-positive value disables the janitor and expired entries are only removed when overwritten
+	JanitorInterval time.Duration
+	// Codec encodes the stored values, defaults to NewJSONCodec
+	Codec Codec
+	// SlidingRetention, if positive, resets the retention of every entry read by Get or GetMany to the given
+	// duration. Reads rewrite the entry files then and require the exclusive lock.
+	SlidingRetention time.Duration
+}
+
+func CreateDefaultDiskCacheConfig() DiskCacheConfig {
+	return DiskCacheConfig{
+		JanitorInterval: 1 * time.Minute,
+		Codec:           NewJSONCodec(),
+	}
+}
+
+// NewDiskCache creates a cache persisting every entry as file in dir, which is created if missing.
+// Several caches, also of different processes, may share dir as long as they agree on the codec. Disk caches
+// are supported on unix and windows, on other platforms ErrUnsupportedPlatform is returned.
+// The cache and its namespace views can be asserted to io.Closer to release their lock files. Expired
+// entries are removed by a janitor every minute until the cache is closed.
+func NewDiskCache[Entity any](
+	dir string,
+) (Cache[Entity], error) {
+	return NewDiskCacheWithConfig[Entity](context.Background(), dir, nil)
+}
+
+// NewDiskCacheWithConfig creates a disk cache whose janitor runs until ctx is done.
+func NewDiskCacheWithConfig[Entity any](
+	ctx context.Context,
+	dir string,
+	config *DiskCacheConfig,
+) (Cache[Entity], error) {
+	var vConfig DiskCacheConfig
+	if config != nil {
+		vConfig = *config
+	} else {
+		vConfig = CreateDefaultDiskCacheConfig()
+	}
+
+	c, err := newDiskCache[Entity](dir, vConfig)
+	if err != nil {
+		return nil, err
+	}
+	if vConfig.JanitorInterval > 0 {
+		// the janitor also stops once the cache is closed
+		go c.janitor(ctx, vConfig.JanitorInterval)
+	}
+	return c, nil
+}
+
+func newDiskCache[Entity any](dir string, config DiskCacheConfig) (*diskCache[Entity], error) {
+	c := newLazyDiskCache[Entity](dir, config)
+	if err := c.open(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// newLazyDiskCache creates a disk cache whose directory and lock file are only created on first use
+func newLazyDiskCache[Entity any](dir string, config DiskCacheConfig) *diskCache[Entity] {
+	c := &diskCache[Entity]{
+		dir:      dir,
+		codec:    config.Codec,
+		config:   config,
+		done:     make(chan struct{}),
+		children: make(map[string]*diskCache[Entity]),
+	}
+	if c.codec == nil {
+		c.codec = NewJSONCodec()
+	}
+	return c
+}
+
+func (c *diskCache[Entity]) open() error {
+	c.openOnce.Do(func() {
+		if c.openErr = os.MkdirAll(c.dir, 0o700); c.openErr != nil {
+			return
+		}
+		c.lock, c.openErr = openFileLock(filepath.Join(c.dir, diskLockFileName))
+	})
+	return c.openErr
+}
+
+func (c *diskCache[Entity]) Entries(
+	ctx context.Context,
+) (map[string]Entity, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("fetching all entries from disk cache '%s'", c.dir)
+	entries := make(map[string]Entity)
+	for entry, err := range c.All(ctx) {
+		if err != nil {
+			return entries, err
+		}
+		entries[entry.Key] = entry.Value
+	}
+	return entries, nil
+}
+
+func (c *diskCache[Entity]) Keys(
+	ctx context.Context,
+) ([]string, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("fetching all keys from disk cache '%s'", c.dir)
+	entries, err := c.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		keys = append(keys, entry.Key)
+	}
+	return keys, nil
+}
+
+func (c *diskCache[Entity]) Values(
+	ctx context.Context,
+) ([]Entity, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("fetching all values from disk cache '%s'", c.dir)
+	values := make([]Entity, 0)
+	for entry, err := range c.All(ctx) {
+		if err != nil {
+			return values, err
+		}
+		values = append(values, entry.Value)
+	}
+	return values, nil
+}
+
+func (c *diskCache[Entity]) All(
+	ctx context.Context,
+) iter.Seq2[Entry[Entity], error] {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("iterating all entries of disk cache '%s'", c.dir)
+	return func(yield func(Entry[Entity], error) bool) {
+		entries, err := c.snapshot()
+		if err != nil {
+			yield(Entry[Entity]{}, err)
+			return
+		}
+		for _, entry := range entries {
+			vPtr, innerErr := decodeEntry[Entity](c.codec, c.codecEntry(entry.Key), entry.Value)
+			if innerErr != nil {
+				yield(Entry[Entity]{}, innerErr)
+				return
+			}
+			if !yield(Entry[Entity]{Key: entry.Key, Value: *vPtr}, nil) {
+				return
+			}
+		}
+	}
+}
+
+func (c *diskCache[Entity]) Set(
+	ctx context.Context,
+	key string,
+	value Entity,
+	retention time.Duration,
+) error {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("setting value of '%s' in disk cache '%s'", key, c.dir)
+	_, err := c.write(key, value, retention, nil)
+	return err
+}
+
+func (c *diskCache[Entity]) Get(
+	ctx context.Context,
+	key string,
+) (*Entity, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("fetching value of '%s' from disk cache '%s'", key, c.dir)
+	var entry *diskEntry
+	err := c.withLock(c.isSliding(), func() error {
+		var err error
+		entry, err = c.loadForRead(key)
+		return err
+	})
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	return decodeEntry[Entity](c.codec, c.codecEntry(key), entry.Value)
+}
+
+func (c *diskCache[Entity]) Remove(
+	ctx context.Context,
+	key string,
+) error {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("removing value of '%s' from disk cache '%s'", key, c.dir)
+	return c.withLock(true, func() error {
+		return c.delete(key)
+	})
+}
+
+func (c *diskCache[Entity]) GetMany(
+	ctx context.Context,
+	keys []string,
+) (map[string]Entity, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("fetching values of %d keys from disk cache '%s'", len(keys), c.dir)
+	values := make(map[string]Entity)
+	errs := make(map[string]error)
+	err := c.withLock(c.isSliding(), func() error {
+		for _, key := range keys {
+			entry, err := c.loadForRead(key)
+			if err != nil {
+				errs[key] = err
+				continue
+			}
+			if entry == nil {
+				continue
+			}
+			vPtr, err := decodeEntry[Entity](c.codec, c.codecEntry(key), entry.Value)
+			if err != nil {
+				errs[key] = err
+				continue
+			}
+			values[key] = *vPtr
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, batchError(errs)
+}
+
+func (c *diskCache[Entity]) SetMany(
+	ctx context.Context,
+	entries map[string]Entity,
+	retention time.Duration,
+) error {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("setting values of %d keys in disk cache '%s'", len(entries), c.dir)
+	errs := make(map[string]error)
+	err := c.withLock(true, func() error {
+		for key, value := range entries {
+			if err := c.store(key, value, retention); err != nil {
+				errs[key] = err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return batchError(errs)
+}
+
+func (c *diskCache[Entity]) RemoveMany(
+	ctx context.Context,
+	keys []string,
+) error {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("removing values of %d keys from disk cache '%s'", len(keys), c.dir)
+	errs := make(map[string]error)
+	err := c.withLock(true, func() error {
+		for _, key := range keys {
+			if err := c.delete(key); err != nil {
+				errs[key] = err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return batchError(errs)
+}
+
+// Clear removes all entry files of the directory, including those of expired entries.
+func (c *diskCache[Entity]) Clear(
+	ctx context.Context,
+) error {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("clearing disk cache '%s'", c.dir)
+	err := c.withLock(true, func() error {
+		return c.removeFiles(func(_ string) bool {
+			return true
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return c.forEachChild(func(child *diskCache[Entity]) error {
+		return child.Clear(ctx)
+	})
+}
+
+func (c *diskCache[Entity]) SetIfAbsent(
+	ctx context.Context,
+	key string,
+	value Entity,
+	retention time.Duration,
+) (bool, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("setting value of '%s' in disk cache '%s' if absent", key, c.dir)
+	return c.write(key, value, retention, func(previous *diskEntry) (bool, error) {
+		return previous == nil, nil
+	})
+}
+
+func (c *diskCache[Entity]) CompareAndSwap(
+	ctx context.Context,
+	key string,
+	oldValue Entity,
+	newValue Entity,
+	retention time.Duration,
+) (bool, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("swapping value of '%s' in disk cache '%s'", key, c.dir)
+	return c.write(key, newValue, retention, func(previous *diskEntry) (bool, error) {
+		return c.entryEquals(key, previous, oldValue)
+	})
+}
+
+func (c *diskCache[Entity]) RemoveIfEquals(
+	ctx context.Context,
+	key string,
+	value Entity,
+) (bool, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("removing value of '%s' from disk cache '%s' if unchanged", key, c.dir)
+	removed := false
+	err := c.withLock(true, func() error {
+		entry, err := c.load(key)
+		if err != nil {
+			return err
+		}
+		if removed, err = c.entryEquals(key, entry, value); err != nil || !removed {
+			return err
+		}
+		return c.delete(key)
+	})
+	return removed, err
+}
+
+func (c *diskCache[Entity]) RemainingRetention(
+	ctx context.Context,
+	key string,
+) (time.Duration, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("fetching remaining retention of '%s' in disk cache '%s'", key, c.dir)
+	var entry *diskEntry
+	err := c.withLock(false, func() error {
+		var err error
+		entry, err = c.load(key)
+		return err
+	})
+	if err != nil || entry == nil {
+		return 0, err
+	}
+	if entry.ExpiresAt.IsZero() {
+		return math.MaxInt64, nil
+	}
+	// the entry might have expired since it was loaded
+	return max(time.Until(entry.ExpiresAt), 0), nil
+}
+
+func (c *diskCache[Entity]) Touch(
+	ctx context.Context,
+	key string,
+	retention time.Duration,
+) (bool, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("touching value of '%s' in disk cache '%s'", key, c.dir)
+	touched := false
+	err := c.withLock(true, func() error {
+		entry, err := c.load(key)
+		if err != nil || entry == nil {
+			return err
+		}
+		if err = c.extend(entry, retention); err != nil {
+			return err
+		}
+		touched = true
+		return nil
+	})
+	return touched, err
+}
+
+// WithNamespace returns a view whose entries are stored in a subdirectory, which is created on first use.
+func (c *diskCache[Entity]) WithNamespace(
+	namespace string,
+) Cache[Entity] {
+	return c.child(namespace)
+}
+
+func (c *diskCache[Entity]) Namespaces(
+	ctx context.Context,
+) ([]string, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("fetching namespaces of disk cache '%s'", c.dir)
+	dirEntries, err := os.ReadDir(c.dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	namespaces := make([]string, 0)
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.IsDir() || !strings.HasPrefix(dirEntry.Name(), diskNamespacePrefix) {
+			continue
+		}
+		namespace, err := url.QueryUnescape(strings.TrimPrefix(dirEntry.Name(), diskNamespacePrefix))
+		if err != nil {
+			return nil, err
+		}
+		namespaces = append(namespaces, namespace)
+	}
+	slices.Sort(namespaces)
+	return namespaces, nil
+}
+
+// child returns the cache of namespace, escaping it so that any namespace is a valid directory name
+func (c *diskCache[Entity]) child(namespace string) *diskCache[Entity] {
+	c.childrenMu.Lock()
+	defer c.childrenMu.Unlock()
+	child, ok := c.children[namespace]
+	if !ok {
+		dir := filepath.Join(c.dir, diskNamespacePrefix+url.QueryEscape(namespace))
+		child = newLazyDiskCache[Entity](dir, c.config)
+		child.namespacePath = c.namespacePath + "/" + url.QueryEscape(namespace)
+		// views obtained after Close are closed as well
+		child.closed.Store(c.closed.Load())
+		c.children[namespace] = child
+	}
+	return child
+}
+
+// Close releases the lock files of the cache and all its namespace views, which fail with fs.ErrClosed afterward.
+// Operations in progress are completed first.
+func (c *diskCache[Entity]) Close() error {
+	if !c.closed.Swap(true) {
+		close(c.done)
+	}
+	c.childrenMu.Lock()
+	children := make([]*diskCache[Entity], 0, len(c.children))
+	for _, child := range c.children {
+		children = append(children, child)
+	}
+	c.childrenMu.Unlock()
+
+	errs := make([]error, 0, len(children)+1)
+	for _, child := range children {
+		errs = append(errs, child.Close())
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lock != nil {
+		errs = append(errs, c.lock.close())
+		c.lock = nil
+	}
+	return errors.Join(errs...)
+}
+
+// forEachChild calls fn for the caches of all namespaces present on disk, including those created by other processes
+func (c *diskCache[Entity]) forEachChild(fn func(child *diskCache[Entity]) error) error {
+	namespaces, err := c.Namespaces(context.Background())
+	if err != nil {
+		return err
+	}
+	for _, namespace := range namespaces {
+		if err = fn(c.child(namespace)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withLock runs fn while holding the process and file lock, exclusive is required for modifications
+func (c *diskCache[Entity]) withLock(exclusive bool, fn func() error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed.Load() {
+		return fs.ErrClosed
+	}
+	if err := c.open(); err != nil {
+		return err
+	}
+	if err := c.lock.lock(exclusive); err != nil {
+		return err
+	}
+	fnErr := fn()
+	return errors.Join(fnErr, c.lock.unlock())
+}
+
+// write stores value if condition, evaluated with the current entry, holds and reports whether it was stored
+func (c *diskCache[Entity]) write(
+	key string,
+	value Entity,
+	retention time.Duration,
+	condition func(previous *diskEntry) (bool, error),
+) (bool, error) {
+	written := false
+	err := c.withLock(true, func() error {
+		if condition != nil {
+			previous, err := c.load(key)
+			if err != nil {
+				return err
+			}
+			if ok, err := condition(previous); err != nil || !ok {
+				return err
+			}
+		}
+		if err := c.store(key, value, retention); err != nil {
+			return err
+		}
+		written = true
+		return nil
+	})
+	return written, err
+}
+
+func (c *diskCache[Entity]) entryEquals(key string, entry *diskEntry, value Entity) (bool, error) {
+	if entry == nil {
+		return false, nil
+	}
+	current, err := decodeEntry[Entity](c.codec, c.codecEntry(key), entry.Value)
+	if err != nil {
+		return false, err
+	}
+	return valuesEqual(*current, value), nil
+}
+
+// load reads the entry of key, nil is returned for missing or expired entries. The caller must hold the lock.
+func (c *diskCache[Entity]) load(key string) (*diskEntry, error) {
+	entry, err := c.readFile(c.entryPath(key))
+	if err != nil || entry == nil || entry.isExpired(time.Now()) {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// loadForRead loads the entry of key and extends its retention in sliding mode, which requires the exclusive lock
+func (c *diskCache[Entity]) loadForRead(key string) (*diskEntry, error) {
+	entry, err := c.load(key)
+	if err != nil || entry == nil || !c.isSliding() {
+		return entry, err
+	}
+	return entry, c.extend(entry, c.config.SlidingRetention)
+}
+
+func (c *diskCache[Entity]) isSliding() bool {
+	return c.config.SlidingRetention > 0
+}
+
+// extend rewrites entry with its expiry reset to retention. The caller must hold the exclusive lock.
+func (c *diskCache[Entity]) extend(entry *diskEntry, retention time.Duration) error {
+	extended := diskEntry{Key: entry.Key, Value: entry.Value}
+	if retention > 0 {
+		extended.ExpiresAt = time.Now().Add(retention)
+	}
+	return c.storeEntry(extended)
+}
+
+// store encodes value and stores it as entry of key. The caller must hold the exclusive lock.
+func (c *diskCache[Entity]) store(key string, value Entity, retention time.Duration) error {
+	data, err := marshalEntry(c.codec, c.codecEntry(key), value)
+	if err != nil {
+		return err
+	}
+	entry := diskEntry{Key: key, Value: data}
+	if retention > 0 {
+		entry.ExpiresAt = time.Now().Add(retention)
+	}
+	return c.storeEntry(entry)
+}
+
+// storeEntry atomically replaces the entry file by writing a temporary file that is renamed afterwards,
+// so readers and crashes never observe partially written entries. The caller must hold the exclusive lock.
+func (c *diskCache[Entity]) storeEntry(entry diskEntry) error {
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(c.dir, diskTempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(content)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), c.entryPath(entry.Key))
+	}
+	if err != nil {
+		return errors.Join(err, os.Remove(file.Name()))
+	}
+	return syncDir(c.dir)
+}
+
+// delete removes the entry file of key. The caller must hold the exclusive lock.
+func (c *diskCache[Entity]) delete(key string) error {
+	if err := os.Remove(c.entryPath(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// snapshot reads all non-expired entries
+func (c *diskCache[Entity]) snapshot() ([]*diskEntry, error) {
+	entries := make([]*diskEntry, 0)
+	err := c.withLock(false, func() error {
+		names, err := c.entryFileNames()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, name := range names {
+			entry, err := c.readFile(filepath.Join(c.dir, name))
+			if err != nil {
+				return err
+			}
+			if entry != nil && !entry.isExpired(now) {
+				entries = append(entries, entry)
+			}
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// readFile decodes the entry file at path, nil is returned if it does not exist
+func (c *diskCache[Entity]) readFile(path string) (*diskEntry, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var entry diskEntry
+	if err = json.Unmarshal(content, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (c *diskCache[Entity]) janitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.removeExpired(); errors.Is(err, fs.ErrClosed) {
+				return
+			} else if err != nil {
+				aulogging.Logger.Ctx(ctx).Warn().WithErr(err).
+					Printf("failed to remove expired entries of disk cache '%s'", c.dir)
+			}
+		}
+	}
+}
+
+// removeExpired removes expired entries as well as temporary files left behind by crashed writers
+func (c *diskCache[Entity]) removeExpired() error {
+	err := c.withLock(true, func() error {
+		if err := c.removeTempFiles(); err != nil {
+			return err
+		}
+		now := time.Now()
+		return c.removeFiles(func(name string) bool {
+			entry, err := c.readFile(filepath.Join(c.dir, name))
+			return err == nil && entry != nil && entry.isExpired(now)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return c.forEachChild(func(child *diskCache[Entity]) error {
+		return child.removeExpired()
+	})
+}
+
+// removeFiles removes the entry files whose name matches. The caller must hold the exclusive lock.
+func (c *diskCache[Entity]) removeFiles(matches func(name string) bool) error {
+	names, err := c.entryFileNames()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if !matches(name) {
+			continue
+		}
+		if err = os.Remove(filepath.Join(c.dir, name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeTempFiles removes temporary files, which can only be left over by crashed writers while the
+// exclusive lock is held. The caller must hold the exclusive lock.
+func (c *diskCache[Entity]) removeTempFiles() error {
+	dirEntries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	for _, dirEntry := range dirEntries {
+		if !strings.HasPrefix(dirEntry.Name(), diskTempPrefix) {
+			continue
+		}
+		if err = os.Remove(filepath.Join(c.dir, dirEntry.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *diskCache[Entity]) entryFileNames() ([]string, error) {
+	dirEntries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.IsDir() && strings.HasSuffix(dirEntry.Name(), diskEntrySuffix) {
+			names = append(names, dirEntry.Name())
+		}
+	}
+	return names, nil
+}
+
+// entryPath derives the file name from the hash of key, as keys may contain characters or exceed
+// lengths not allowed in file names
+// codecEntry identifies the entry of key to the codec by its namespaces and key, independent of dir
+func (c *diskCache[Entity]) codecEntry(key string) string {
+	return c.namespacePath + "|" + key
+}
+
+func (c *diskCache[Entity]) entryPath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+diskEntrySuffix)
+}
+
+func (e *diskEntry) isExpired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && !now.Before(e.ExpiresAt)
+}
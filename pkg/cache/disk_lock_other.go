@@ -0,0 +1,31 @@
+//go:build !unix && !windows
+
+package cache
+
+import (
+	"runtime"
+)
+
+// fileLock is not available on platforms without flock or LockFileEx, disk caches are rejected there
+// as processes sharing a directory could not be coordinated
+type fileLock struct{}
+
+func openFileLock(_ string) (*fileLock, error) {
+	return nil, NewErrUnsupportedPlatform(runtime.GOOS)
+}
+
+func (l *fileLock) lock(_ bool) error {
+	return NewErrUnsupportedPlatform(runtime.GOOS)
+}
+
+func (l *fileLock) unlock() error {
+	return nil
+}
+
+func (l *fileLock) close() error {
+	return nil
+}
+
+func syncDir(_ string) error {
+	return nil
+}
@@ -0,0 +1,44 @@
+//go:build windows
+
+package cache
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// fileLock coordinates the processes sharing a disk cache directory using LockFileEx
+type fileLock struct {
+	file *os.File
+}
+
+func openFileLock(path string) (*fileLock, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &fileLock{file: file}, nil
+}
+
+func (l *fileLock) lock(exclusive bool) error {
+	var flags uint32
+	if exclusive {
+		flags = windows.LOCKFILE_EXCLUSIVE_LOCK
+	}
+	// the first byte of the file is locked, which works for empty files as well
+	return windows.LockFileEx(windows.Handle(l.file.Fd()), flags, 0, 1, 0, new(windows.Overlapped))
+}
+
+func (l *fileLock) unlock() error {
+	return windows.UnlockFileEx(windows.Handle(l.file.Fd()), 0, 1, 0, new(windows.Overlapped))
+}
+
+func (l *fileLock) close() error {
+	return l.file.Close()
+}
+
+// syncDir is a no-op as directories cannot be synced on windows
+func syncDir(_ string) error {
+	return nil
+}
@@ -0,0 +1,208 @@
+package cache
+
+import (
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func TestDiskCache(t *testing.T) {
+	ctx := context.TODO()
+	dir := t.TempDir()
+	cut, err := NewDiskCache[demoEntity](dir)
+	require.Nil(t, err)
+
+	e1 := demoEntity{Value1: "first", Value2: p("second")}
+	require.Nil(t, cut.Set(ctx, "path/with:odd*chars", e1, 0))
+
+	got, err := cut.Get(ctx, "path/with:odd*chars")
+	require.Nil(t, err)
+	require.EqualValues(t, e1, *got)
+
+	keys, err := cut.Keys(ctx)
+	require.Nil(t, err)
+	require.Equal(t, []string{"path/with:odd*chars"}, keys)
+
+	retention, err := cut.RemainingRetention(ctx, "path/with:odd*chars")
+	require.Nil(t, err)
+	require.Equal(t, time.Duration(math.MaxInt64), retention)
+
+	// a new instance, e.g. after a restart, sees the persisted entries
+	restarted, err := NewDiskCache[demoEntity](dir)
+	require.Nil(t, err)
+	got, err = restarted.Get(ctx, "path/with:odd*chars")
+	require.Nil(t, err)
+	require.EqualValues(t, e1, *got)
+
+	require.Nil(t, restarted.Remove(ctx, "path/with:odd*chars"))
+	got, err = cut.Get(ctx, "path/with:odd*chars")
+	require.Nil(t, err)
+	require.Nil(t, got)
+}
+
+func TestDiskCacheRetention(t *testing.T) {
+	ctx := context.TODO()
+	cut, err := NewDiskCache[string](t.TempDir())
+	require.Nil(t, err)
+
+	require.Nil(t, cut.Set(ctx, "key1", "value1", 20*time.Millisecond))
+	retention, err := cut.RemainingRetention(ctx, "key1")
+	require.Nil(t, err)
+	require.LessOrEqual(t, retention, 20*time.Millisecond)
+
+	time.Sleep(30 * time.Millisecond)
+	got, err := cut.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.Nil(t, got)
+	stored, err := cut.SetIfAbsent(ctx, "key1", "value2", 0)
+	require.Nil(t, err)
+	require.True(t, stored)
+}
+
+func TestDiskCacheTouch(t *testing.T) {
+	ctx := context.TODO()
+	dir := t.TempDir()
+	cut, err := NewDiskCache[string](dir)
+	require.Nil(t, err)
+
+	require.Nil(t, cut.Set(ctx, "key1", "value1", time.Minute))
+	touched, err := cut.Touch(ctx, "key1", time.Hour)
+	require.Nil(t, err)
+	require.True(t, touched)
+
+	// the extended retention is persisted
+	restarted, err := NewDiskCache[string](dir)
+	require.Nil(t, err)
+	retention, err := restarted.RemainingRetention(ctx, "key1")
+	require.Nil(t, err)
+	require.Greater(t, retention, 59*time.Minute)
+	got, err := restarted.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.Equal(t, "value1", *got)
+
+	touched, err = cut.Touch(ctx, "key1", 0)
+	require.Nil(t, err)
+	require.True(t, touched)
+	retention, err = cut.RemainingRetention(ctx, "key1")
+	require.Nil(t, err)
+	require.Equal(t, time.Duration(math.MaxInt64), retention)
+
+	touched, err = cut.Touch(ctx, "missing", time.Hour)
+	require.Nil(t, err)
+	require.False(t, touched)
+}
+
+func TestDiskCacheSlidingRetention(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	cut, err := NewDiskCacheWithConfig[string](ctx, t.TempDir(), &DiskCacheConfig{
+		SlidingRetention: time.Hour,
+	})
+	require.Nil(t, err)
+
+	require.Nil(t, cut.SetMany(ctx, map[string]string{"key1": "value1", "key2": "value2"}, time.Minute))
+	got, err := cut.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.Equal(t, "value1", *got)
+
+	retention, err := cut.RemainingRetention(ctx, "key1")
+	require.Nil(t, err)
+	require.Greater(t, retention, 59*time.Minute)
+	retention, err = cut.RemainingRetention(ctx, "key2")
+	require.Nil(t, err)
+	require.LessOrEqual(t, retention, time.Minute)
+
+	values, err := cut.GetMany(ctx, []string{"key2", "missing"})
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{"key2": "value2"}, values)
+	retention, err = cut.RemainingRetention(ctx, "key2")
+	require.Nil(t, err)
+	require.Greater(t, retention, 59*time.Minute)
+}
+
+func TestDiskCacheJanitor(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	dir := t.TempDir()
+	cut, err := NewDiskCacheWithConfig[string](ctx, dir, &DiskCacheConfig{JanitorInterval: 10 * time.Millisecond})
+	require.Nil(t, err)
+
+	require.Nil(t, cut.Set(ctx, "key1", "value1", 10*time.Millisecond))
+	require.Nil(t, os.WriteFile(dir+"/"+diskTempPrefix+"crashed", []byte("partial"), 0o600))
+
+	require.Eventually(t, func() bool {
+		dirEntries, err := os.ReadDir(dir)
+		// only the lock file is kept
+		return err == nil && len(dirEntries) == 1
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestDiskCacheConditionalWrites(t *testing.T) {
+	ctx := context.TODO()
+	cut, err := NewDiskCache[demoEntity](t.TempDir())
+	require.Nil(t, err)
+
+	e1 := demoEntity{Value1: "first"}
+	e2 := demoEntity{Value1: "second"}
+	stored, err := cut.SetIfAbsent(ctx, "key1", e1, 0)
+	require.Nil(t, err)
+	require.True(t, stored)
+	stored, err = cut.SetIfAbsent(ctx, "key1", e2, 0)
+	require.Nil(t, err)
+	require.False(t, stored)
+
+	swapped, err := cut.CompareAndSwap(ctx, "key1", e2, e1, 0)
+	require.Nil(t, err)
+	require.False(t, swapped)
+	swapped, err = cut.CompareAndSwap(ctx, "key1", e1, e2, 0)
+	require.Nil(t, err)
+	require.True(t, swapped)
+
+	removed, err := cut.RemoveIfEquals(ctx, "key1", e1)
+	require.Nil(t, err)
+	require.False(t, removed)
+	removed, err = cut.RemoveIfEquals(ctx, "key1", e2)
+	require.Nil(t, err)
+	require.True(t, removed)
+
+	require.Nil(t, cut.SetMany(ctx, map[string]demoEntity{"key1": e1, "key2": e2}, 0))
+	require.Nil(t, cut.Clear(ctx))
+	entries, err := cut.Entries(ctx)
+	require.Nil(t, err)
+	require.Empty(t, entries)
+}
+
+func TestDiskCacheClose(t *testing.T) {
+	ctx := context.TODO()
+	cut, err := NewDiskCache[string](t.TempDir())
+	require.Nil(t, err)
+	view := cut.WithNamespace("tenant")
+	require.Nil(t, cut.Set(ctx, "key1", "value1", 0))
+	require.Nil(t, view.Set(ctx, "key1", "value1", 0))
+
+	require.Nil(t, cut.(io.Closer).Close())
+	require.ErrorIs(t, cut.Set(ctx, "key1", "value2", 0), fs.ErrClosed)
+	require.ErrorIs(t, view.Set(ctx, "key1", "value2", 0), fs.ErrClosed)
+	_, err = cut.WithNamespace("other").Get(ctx, "key1")
+	require.ErrorIs(t, err, fs.ErrClosed)
+	require.Nil(t, cut.(io.Closer).Close())
+}
+
+func TestDiskCacheNamespacesAreSorted(t *testing.T) {
+	ctx := context.TODO()
+	cut, err := NewDiskCache[string](t.TempDir())
+	require.Nil(t, err)
+	// the escaped directory names sort differently than the namespaces
+	require.Nil(t, cut.WithNamespace("a/b").Set(ctx, "key1", "value1", 0))
+	require.Nil(t, cut.WithNamespace("a-b").Set(ctx, "key1", "value1", 0))
+
+	namespaces, err := cut.(NamespaceLister).Namespaces(ctx)
+	require.Nil(t, err)
+	require.Equal(t, []string{"a-b", "a/b"}, namespaces)
+}
@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+)
+
+// encryptionMarkerAESGCM precedes every value written by an encrypting codec, it is followed by the
+// length of the key ID, the key ID, the nonce and the sealed value
+const encryptionMarkerAESGCM byte = 0x02
+
+// KeyProvider supplies the AES keys of an encrypting codec, keys must be 16, 24 or 32 bytes long.
+// To rotate keys, add the new key and make it current while keeping the previous ones until all
+// values encrypted with them have expired.
+type KeyProvider interface {
+	// CurrentKey returns the key used to encrypt new values together with its ID of at most 255 bytes
+	CurrentKey() (string, []byte, error)
+
+	// Key returns the key with the given ID, used to decrypt values, or ErrUnknownEncryptionKey
+	Key(keyID string) ([]byte, error)
+}
+
+type staticKeyProvider struct {
+	currentKeyID string
+	keys         map[string][]byte
+}
+
+// NewStaticKeyProvider creates a provider of a fixed set of keys by ID, currentKeyID selects the one used for encryption.
+func NewStaticKeyProvider(currentKeyID string, keys map[string][]byte) KeyProvider {
+	return &staticKeyProvider{
+		currentKeyID: currentKeyID,
+		keys:         keys,
+	}
+}
+
+func (p *staticKeyProvider) CurrentKey() (string, []byte, error) {
+	key, err := p.Key(p.currentKeyID)
+	return p.currentKeyID, key, err
+}
+
+func (p *staticKeyProvider) Key(keyID string) ([]byte, error) {
+	key, ok := p.keys[keyID]
+	if !ok {
+		return nil, NewErrUnknownEncryptionKey(keyID)
+	}
+	return key, nil
+}
+
+type encryptingCodec struct {
+	codec       Codec
+	keyProvider KeyProvider
+}
+
+// NewEncryptingCodec wraps codec and encrypts all encoded values with AES-GCM using the current key of
+// keyProvider. The key ID is stored with every value, so values remain readable after a key rotation.
+// Values not written by an encrypting codec are rejected. Caches storing values outside the process
+// bind every value to its entry, values copied to another entry, cache or namespace fail to decrypt,
+// so entries cannot be moved by renaming them in the store. When combined with compression, the
+// compressing codec has to be wrapped, as encrypted data does not compress.
+func NewEncryptingCodec(codec Codec, keyProvider KeyProvider) Codec {
+	return &encryptingCodec{
+		codec:       codec,
+		keyProvider: keyProvider,
+	}
+}
+
+func (c *encryptingCodec) Marshal(value any) ([]byte, error) {
+	return c.MarshalEntry("", value)
+}
+
+func (c *encryptingCodec) MarshalEntry(entry string, value any) ([]byte, error) {
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	keyID, key, err := c.keyProvider.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	if len(keyID) > 255 {
+		return nil, NewErrEncryptionKeyIDTooLong(keyID)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := append([]byte{encryptionMarkerAESGCM, byte(len(keyID))}, keyID...)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	result := make([]byte, 0, len(header)+len(nonce)+len(data)+aead.Overhead())
+	result = append(append(result, header...), nonce...)
+	// the header and the entry are authenticated, so neither the key ID can be tampered with
+	// nor the value be moved to another entry
+	return aead.Seal(result, nonce, data, associatedData(header, entry)), nil
+}
+
+func (c *encryptingCodec) Unmarshal(data []byte, value any) error {
+	return c.UnmarshalEntry("", data, value)
+}
+
+func (c *encryptingCodec) UnmarshalEntry(entry string, data []byte, value any) error {
+	if len(data) < 2 || data[0] != encryptionMarkerAESGCM {
+		return NewErrMalformedEncryptedValue("value is not encrypted")
+	}
+	headerSize := 2 + int(data[1])
+	if len(data) < headerSize {
+		return NewErrMalformedEncryptedValue("value is truncated")
+	}
+	header := data[:headerSize]
+	key, err := c.keyProvider.Key(string(header[2:]))
+	if err != nil {
+		return err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+
+	if len(data) < headerSize+aead.NonceSize() {
+		return NewErrMalformedEncryptedValue("value is truncated")
+	}
+	nonce := data[headerSize : headerSize+aead.NonceSize()]
+	plain, err := aead.Open(nil, nonce, data[headerSize+aead.NonceSize():], associatedData(header, entry))
+	if err != nil {
+		return err
+	}
+	return c.codec.Unmarshal(plain, value)
+}
+
+func associatedData(header []byte, entry string) []byte {
+	return append(bytes.Clone(header), entry...)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,192 @@
+package cache
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type ErrEntryTooLarge struct {
+	key     string
+	size    int64
+	maxSize int64
+}
+
+func (e ErrEntryTooLarge) Error() string {
+	return fmt.Sprintf("entry '%s' of %d bytes exceeds the maximum cache size of %d bytes", e.key, e.size, e.maxSize)
+}
+
+func NewErrEntryTooLarge(
+	key string,
+	size int64,
+	maxSize int64,
+) ErrEntryTooLarge {
+	return ErrEntryTooLarge{
+		key:     key,
+		size:    size,
+		maxSize: maxSize,
+	}
+}
+
+// ErrBatch collects the per-key failures of a batch operation, keys not contained succeeded
+type ErrBatch struct {
+	errs map[string]error
+}
+
+func (e ErrBatch) Error() string {
+	keys := make([]string, 0, len(e.errs))
+	for key := range e.errs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	messages := make([]string, 0, len(keys))
+	for _, key := range keys {
+		messages = append(messages, fmt.Sprintf("'%s': %v", key, e.errs[key]))
+	}
+	return fmt.Sprintf("batch operation failed for %d keys: %s", len(e.errs), strings.Join(messages, "; "))
+}
+
+func (e ErrBatch) Unwrap() []error {
+	errs := make([]error, 0, len(e.errs))
+	for _, err := range e.errs {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func (e ErrBatch) KeyErrors() map[string]error {
+	return e.errs
+}
+
+func NewErrBatch(errs map[string]error) ErrBatch {
+	return ErrBatch{errs: errs}
+}
+
+// batchError returns nil if no key failed so that callers can return it unconditionally
+func batchError(errs map[string]error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return NewErrBatch(errs)
+}
+
+type ErrUnsupportedValueType struct {
+	valueType string
+}
+
+func (e ErrUnsupportedValueType) Error() string {
+	return fmt.Sprintf("codec does not support values of type '%s'", e.valueType)
+}
+
+func NewErrUnsupportedValueType(value any) ErrUnsupportedValueType {
+	return ErrUnsupportedValueType{valueType: fmt.Sprintf("%T", value)}
+}
+
+type ErrWatchDisabled struct {
+	key string
+}
+
+func (e ErrWatchDisabled) Error() string {
+	return fmt.Sprintf("cache '%s' does not publish events, watching it is not possible", e.key)
+}
+
+func NewErrWatchDisabled(key string) ErrWatchDisabled {
+	return ErrWatchDisabled{key: key}
+}
+
+type ErrLoadPanicked struct {
+	key       string
+	recovered any
+}
+
+func (e ErrLoadPanicked) Error() string {
+	return fmt.Sprintf("loading '%s' panicked: %v", e.key, e.recovered)
+}
+
+func NewErrLoadPanicked(key string, recovered any) ErrLoadPanicked {
+	return ErrLoadPanicked{key: key, recovered: recovered}
+}
+
+type ErrExpvarNameTaken struct {
+	name string
+}
+
+func (e ErrExpvarNameTaken) Error() string {
+	return fmt.Sprintf("expvar name '%s' is already published as other than a map", e.name)
+}
+
+func NewErrExpvarNameTaken(name string) ErrExpvarNameTaken {
+	return ErrExpvarNameTaken{name: name}
+}
+
+type ErrUnknownEncryptionKey struct {
+	keyID string
+}
+
+func (e ErrUnknownEncryptionKey) Error() string {
+	return fmt.Sprintf("encryption key '%s' is unknown", e.keyID)
+}
+
+func NewErrUnknownEncryptionKey(keyID string) ErrUnknownEncryptionKey {
+	return ErrUnknownEncryptionKey{keyID: keyID}
+}
+
+type ErrEncryptionKeyIDTooLong struct {
+	keyID string
+}
+
+func (e ErrEncryptionKeyIDTooLong) Error() string {
+	return fmt.Sprintf("encryption key ID '%s' exceeds 255 bytes", e.keyID)
+}
+
+func NewErrEncryptionKeyIDTooLong(keyID string) ErrEncryptionKeyIDTooLong {
+	return ErrEncryptionKeyIDTooLong{keyID: keyID}
+}
+
+type ErrMalformedEncryptedValue struct {
+	reason string
+}
+
+func (e ErrMalformedEncryptedValue) Error() string {
+	return fmt.Sprintf("encrypted value is malformed: %s", e.reason)
+}
+
+func NewErrMalformedEncryptedValue(reason string) ErrMalformedEncryptedValue {
+	return ErrMalformedEncryptedValue{reason: reason}
+}
+
+type ErrMalformedMetadata struct {
+	reason string
+}
+
+func (e ErrMalformedMetadata) Error() string {
+	return fmt.Sprintf("entry metadata is malformed: %s", e.reason)
+}
+
+func NewErrMalformedMetadata(reason string) ErrMalformedMetadata {
+	return ErrMalformedMetadata{reason: reason}
+}
+
+type ErrUnsupportedOperation struct {
+	operation string
+}
+
+func (e ErrUnsupportedOperation) Error() string {
+	return fmt.Sprintf("operation '%s' is not supported by the underlying cache", e.operation)
+}
+
+func NewErrUnsupportedOperation(operation string) ErrUnsupportedOperation {
+	return ErrUnsupportedOperation{operation: operation}
+}
+
+type ErrUnsupportedPlatform struct {
+	platform string
+}
+
+func (e ErrUnsupportedPlatform) Error() string {
+	return fmt.Sprintf("disk caches are not supported on platform '%s'", e.platform)
+}
+
+func NewErrUnsupportedPlatform(platform string) ErrUnsupportedPlatform {
+	return ErrUnsupportedPlatform{platform: platform}
+}
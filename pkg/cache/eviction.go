@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"container/list"
+	"math"
+)
+
+type EvictionReason int64
+
+const (
+	EvictionReasonCapacity EvictionReason = iota
+	EvictionReasonExpired
+)
+
+// EvictionPolicy decides which entry a bounded cache drops once it exceeds its capacity.
+// Implementations are always called under the lock of the owning cache and need not be thread-safe.
+type EvictionPolicy interface {
+	Added(key string)
+	Accessed(key string)
+	Removed(key string)
+	Victim() (string, bool)
+}
+
+// recencyPolicy backs both LRU and FIFO, the only difference being whether accesses reorder entries
+type recencyPolicy struct {
+	order          *list.List
+	elements       map[string]*list.Element
+	reorderOnTouch bool
+}
+
+func NewLRUEvictionPolicy() EvictionPolicy {
+	return &recencyPolicy{
+		order:          list.New(),
+		elements:       make(map[string]*list.Element),
+		reorderOnTouch: true,
+	}
+}
+
+func NewFIFOEvictionPolicy() EvictionPolicy {
+	return &recencyPolicy{
+		order:          list.New(),
+		elements:       make(map[string]*list.Element),
+		reorderOnTouch: false,
+	}
+}
+
+func (p *recencyPolicy) Added(key string) {
+	if element, ok := p.elements[key]; ok {
+		if p.reorderOnTouch {
+			p.order.MoveToFront(element)
+		}
+		return
+	}
+	p.elements[key] = p.order.PushFront(key)
+}
+
+func (p *recencyPolicy) Accessed(key string) {
+	if element, ok := p.elements[key]; ok && p.reorderOnTouch {
+		p.order.MoveToFront(element)
+	}
+}
+
+func (p *recencyPolicy) Removed(key string) {
+	if element, ok := p.elements[key]; ok {
+		p.order.Remove(element)
+		delete(p.elements, key)
+	}
+}
+
+func (p *recencyPolicy) Victim() (string, bool) {
+	element := p.order.Back()
+	if element == nil {
+		return "", false
+	}
+	return element.Value.(string), true
+}
+
+type lfuItem struct {
+	key       string
+	frequency int64
+	element   *list.Element
+}
+
+// lfuPolicy keeps one list per access frequency, ties are broken by evicting the least recently used entry.
+// The entry added last is spared while others remain, as it would otherwise always have the lowest frequency
+// and be evicted right away.
+type lfuPolicy struct {
+	items        map[string]*lfuItem
+	frequencies  map[int64]*list.List
+	minFrequency int64
+	added        string
+}
+
+func NewLFUEvictionPolicy() EvictionPolicy {
+	return &lfuPolicy{
+		items:       make(map[string]*lfuItem),
+		frequencies: make(map[int64]*list.List),
+	}
+}
+
+func (p *lfuPolicy) Added(key string) {
+	p.added = key
+	if _, ok := p.items[key]; ok {
+		p.Accessed(key)
+		return
+	}
+	item := &lfuItem{key: key, frequency: 1}
+	item.element = p.bucket(1).PushFront(item)
+	p.items[key] = item
+	p.minFrequency = 1
+}
+
+func (p *lfuPolicy) Accessed(key string) {
+	item, ok := p.items[key]
+	if !ok {
+		return
+	}
+	p.unlink(item)
+	item.frequency++
+	item.element = p.bucket(item.frequency).PushFront(item)
+}
+
+func (p *lfuPolicy) Removed(key string) {
+	item, ok := p.items[key]
+	if !ok {
+		return
+	}
+	p.unlink(item)
+	delete(p.items, key)
+	if p.added == key {
+		p.added = ""
+	}
+}
+
+func (p *lfuPolicy) Victim() (string, bool) {
+	if len(p.items) == 0 {
+		return "", false
+	}
+	for p.frequencies[p.minFrequency] == nil {
+		p.minFrequency++
+	}
+	victim := p.frequencies[p.minFrequency].Back()
+	if victim.Value.(*lfuItem).key == p.added && len(p.items) > 1 {
+		if victim.Prev() != nil {
+			victim = victim.Prev()
+		} else {
+			victim = p.frequencies[p.nextFrequency(p.minFrequency)].Back()
+		}
+	}
+	return victim.Value.(*lfuItem).key, true
+}
+
+// nextFrequency returns the lowest frequency above frequency that has entries
+func (p *lfuPolicy) nextFrequency(frequency int64) int64 {
+	next := int64(math.MaxInt64)
+	for candidate := range p.frequencies {
+		if candidate > frequency && candidate < next {
+			next = candidate
+		}
+	}
+	return next
+}
+
+func (p *lfuPolicy) bucket(frequency int64) *list.List {
+	bucket, ok := p.frequencies[frequency]
+	if !ok {
+		bucket = list.New()
+		p.frequencies[frequency] = bucket
+	}
+	return bucket
+}
+
+func (p *lfuPolicy) unlink(item *lfuItem) {
+	bucket := p.frequencies[item.frequency]
+	bucket.Remove(item.element)
+	if bucket.Len() == 0 {
+		delete(p.frequencies, item.frequency)
+		if p.minFrequency == item.frequency {
+			p.minFrequency++
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"expvar"
+	"sync"
+	"time"
+)
+
+type expvarObserver struct {
+	mu     sync.Mutex
+	root   *expvar.Map
+	caches map[string]*expvar.Map
+}
+
+// expvarMu serialises the lookup and publication of observer maps, as expvar.NewMap panics on reused names
+var expvarMu sync.Mutex
+
+// NewExpvarObserver publishes the metrics of all observed caches as expvar map with the given name.
+// Per cache name it contains hits, misses, sets, removals and errors as well as count, errors and
+// total duration in nanoseconds per operation. Creating the observer twice with the same name reuses the map,
+// ErrExpvarNameTaken is returned if the name is published as another kind of variable.
+func NewExpvarObserver(name string) (Observer, error) {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+	var root *expvar.Map
+	switch published := expvar.Get(name).(type) {
+	case nil:
+		root = expvar.NewMap(name)
+	case *expvar.Map:
+		root = published
+	default:
+		return nil, NewErrExpvarNameTaken(name)
+	}
+	return &expvarObserver{
+		root:   root,
+		caches: make(map[string]*expvar.Map),
+	}, nil
+}
+
+func (o *expvarObserver) OnHits(cacheName string, count int) {
+	o.cache(cacheName).Add("hits", int64(count))
+}
+
+func (o *expvarObserver) OnMisses(cacheName string, count int) {
+	o.cache(cacheName).Add("misses", int64(count))
+}
+
+func (o *expvarObserver) OnSets(cacheName string, count int) {
+	o.cache(cacheName).Add("sets", int64(count))
+}
+
+func (o *expvarObserver) OnRemovals(cacheName string, count int) {
+	o.cache(cacheName).Add("removals", int64(count))
+}
+
+func (o *expvarObserver) OnError(cacheName string, operation Operation, _ error) {
+	metrics := o.cache(cacheName)
+	metrics.Add("errors", 1)
+	metrics.Add(string(operation)+"_errors", 1)
+}
+
+func (o *expvarObserver) OnOperation(cacheName string, operation Operation, duration time.Duration) {
+	metrics := o.cache(cacheName)
+	metrics.Add(string(operation)+"_count", 1)
+	metrics.Add(string(operation)+"_duration_ns", duration.Nanoseconds())
+}
+
+func (o *expvarObserver) cache(cacheName string) *expvar.Map {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	metrics, ok := o.caches[cacheName]
+	if !ok {
+		if metrics, ok = o.root.Get(cacheName).(*expvar.Map); !ok {
+			metrics = new(expvar.Map)
+			o.root.Set(cacheName, metrics)
+		}
+		o.caches[cacheName] = metrics
+	}
+	return metrics
+}
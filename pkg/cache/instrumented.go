@@ -0,0 +1,325 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"time"
+)
+
+type Operation string
+
+const (
+	OperationEntries            Operation = "entries"
+	OperationKeys               Operation = "keys"
+	OperationValues             Operation = "values"
+	OperationAll                Operation = "all"
+	OperationSet                Operation = "set"
+	OperationGet                Operation = "get"
+	OperationGetWithMetadata    Operation = "get_with_metadata"
+	OperationRemove             Operation = "remove"
+	OperationGetMany            Operation = "get_many"
+	OperationSetMany            Operation = "set_many"
+	OperationRemoveMany         Operation = "remove_many"
+	OperationClear              Operation = "clear"
+	OperationSetIfAbsent        Operation = "set_if_absent"
+	OperationCompareAndSwap     Operation = "compare_and_swap"
+	OperationRemoveIfEquals     Operation = "remove_if_equals"
+	OperationRemainingRetention Operation = "remaining_retention"
+	OperationTouch              Operation = "touch"
+	OperationWatch              Operation = "watch"
+	OperationNamespaces         Operation = "namespaces"
+)
+
+// Observer receives the measurements of instrumented caches, implementations must be thread-safe.
+type Observer interface {
+	OnHits(cacheName string, count int)
+
+	OnMisses(cacheName string, count int)
+
+	OnSets(cacheName string, count int)
+
+	OnRemovals(cacheName string, count int)
+
+	OnError(cacheName string, operation Operation, err error)
+
+	OnOperation(cacheName string, operation Operation, duration time.Duration)
+}
+
+type instrumentedCache[Entity any] struct {
+	name     string
+	cache    Cache[Entity]
+	observer Observer
+}
+
+// NewInstrumentedCache wraps cache and reports all operations on it to observer under the given name.
+// The returned cache implements WatchableCache, MetadataCache and NamespaceLister, which fail if cache
+// does not implement them.
+func NewInstrumentedCache[Entity any](
+	name string,
+	cache Cache[Entity],
+	observer Observer,
+) Cache[Entity] {
+	return &instrumentedCache[Entity]{
+		name:     name,
+		cache:    cache,
+		observer: observer,
+	}
+}
+
+func (c *instrumentedCache[Entity]) Entries(
+	ctx context.Context,
+) (map[string]Entity, error) {
+	defer c.observe(OperationEntries, time.Now())
+	entries, err := c.cache.Entries(ctx)
+	return entries, c.observeError(OperationEntries, err)
+}
+
+func (c *instrumentedCache[Entity]) Keys(
+	ctx context.Context,
+) ([]string, error) {
+	defer c.observe(OperationKeys, time.Now())
+	keys, err := c.cache.Keys(ctx)
+	return keys, c.observeError(OperationKeys, err)
+}
+
+func (c *instrumentedCache[Entity]) Values(
+	ctx context.Context,
+) ([]Entity, error) {
+	defer c.observe(OperationValues, time.Now())
+	values, err := c.cache.Values(ctx)
+	return values, c.observeError(OperationValues, err)
+}
+
+// All reports the duration of the complete iteration, including the time spent by the consumer.
+func (c *instrumentedCache[Entity]) All(
+	ctx context.Context,
+) iter.Seq2[Entry[Entity], error] {
+	return func(yield func(Entry[Entity], error) bool) {
+		defer c.observe(OperationAll, time.Now())
+		for entry, err := range c.cache.All(ctx) {
+			c.observeError(OperationAll, err)
+			if !yield(entry, err) {
+				return
+			}
+		}
+	}
+}
+
+func (c *instrumentedCache[Entity]) Set(
+	ctx context.Context,
+	key string,
+	value Entity,
+	retention time.Duration,
+) error {
+	defer c.observe(OperationSet, time.Now())
+	err := c.cache.Set(ctx, key, value, retention)
+	if err == nil {
+		c.observer.OnSets(c.name, 1)
+	}
+	return c.observeError(OperationSet, err)
+}
+
+func (c *instrumentedCache[Entity]) Get(
+	ctx context.Context,
+	key string,
+) (*Entity, error) {
+	defer c.observe(OperationGet, time.Now())
+	value, err := c.cache.Get(ctx, key)
+	if err == nil && value != nil {
+		c.observer.OnHits(c.name, 1)
+	} else if err == nil {
+		c.observer.OnMisses(c.name, 1)
+	}
+	return value, c.observeError(OperationGet, err)
+}
+
+func (c *instrumentedCache[Entity]) GetWithMetadata(
+	ctx context.Context,
+	key string,
+) (*Entity, *EntryMetadata, error) {
+	defer c.observe(OperationGetWithMetadata, time.Now())
+	metadataCache, ok := c.cache.(MetadataCache[Entity])
+	if !ok {
+		err := NewErrUnsupportedOperation(string(OperationGetWithMetadata))
+		return nil, nil, c.observeError(OperationGetWithMetadata, err)
+	}
+	value, metadata, err := metadataCache.GetWithMetadata(ctx, key)
+	if err == nil && value != nil {
+		c.observer.OnHits(c.name, 1)
+	} else if err == nil {
+		c.observer.OnMisses(c.name, 1)
+	}
+	return value, metadata, c.observeError(OperationGetWithMetadata, err)
+}
+
+func (c *instrumentedCache[Entity]) Remove(
+	ctx context.Context,
+	key string,
+) error {
+	defer c.observe(OperationRemove, time.Now())
+	err := c.cache.Remove(ctx, key)
+	if err == nil {
+		c.observer.OnRemovals(c.name, 1)
+	}
+	return c.observeError(OperationRemove, err)
+}
+
+func (c *instrumentedCache[Entity]) GetMany(
+	ctx context.Context,
+	keys []string,
+) (map[string]Entity, error) {
+	defer c.observe(OperationGetMany, time.Now())
+	values, err := c.cache.GetMany(ctx, keys)
+	if keyErrors, ok := batchKeyErrors(err); ok {
+		c.observer.OnHits(c.name, len(values))
+		c.observer.OnMisses(c.name, len(keys)-len(values)-len(keyErrors))
+	}
+	return values, c.observeError(OperationGetMany, err)
+}
+
+func (c *instrumentedCache[Entity]) SetMany(
+	ctx context.Context,
+	entries map[string]Entity,
+	retention time.Duration,
+) error {
+	defer c.observe(OperationSetMany, time.Now())
+	err := c.cache.SetMany(ctx, entries, retention)
+	if keyErrors, ok := batchKeyErrors(err); ok {
+		c.observer.OnSets(c.name, len(entries)-len(keyErrors))
+	}
+	return c.observeError(OperationSetMany, err)
+}
+
+func (c *instrumentedCache[Entity]) RemoveMany(
+	ctx context.Context,
+	keys []string,
+) error {
+	defer c.observe(OperationRemoveMany, time.Now())
+	err := c.cache.RemoveMany(ctx, keys)
+	if keyErrors, ok := batchKeyErrors(err); ok {
+		c.observer.OnRemovals(c.name, len(keys)-len(keyErrors))
+	}
+	return c.observeError(OperationRemoveMany, err)
+}
+
+func (c *instrumentedCache[Entity]) Clear(
+	ctx context.Context,
+) error {
+	defer c.observe(OperationClear, time.Now())
+	return c.observeError(OperationClear, c.cache.Clear(ctx))
+}
+
+func (c *instrumentedCache[Entity]) SetIfAbsent(
+	ctx context.Context,
+	key string,
+	value Entity,
+	retention time.Duration,
+) (bool, error) {
+	defer c.observe(OperationSetIfAbsent, time.Now())
+	stored, err := c.cache.SetIfAbsent(ctx, key, value, retention)
+	if stored {
+		c.observer.OnSets(c.name, 1)
+	}
+	return stored, c.observeError(OperationSetIfAbsent, err)
+}
+
+func (c *instrumentedCache[Entity]) CompareAndSwap(
+	ctx context.Context,
+	key string,
+	oldValue Entity,
+	newValue Entity,
+	retention time.Duration,
+) (bool, error) {
+	defer c.observe(OperationCompareAndSwap, time.Now())
+	swapped, err := c.cache.CompareAndSwap(ctx, key, oldValue, newValue, retention)
+	if swapped {
+		c.observer.OnSets(c.name, 1)
+	}
+	return swapped, c.observeError(OperationCompareAndSwap, err)
+}
+
+func (c *instrumentedCache[Entity]) RemoveIfEquals(
+	ctx context.Context,
+	key string,
+	value Entity,
+) (bool, error) {
+	defer c.observe(OperationRemoveIfEquals, time.Now())
+	removed, err := c.cache.RemoveIfEquals(ctx, key, value)
+	if removed {
+		c.observer.OnRemovals(c.name, 1)
+	}
+	return removed, c.observeError(OperationRemoveIfEquals, err)
+}
+
+func (c *instrumentedCache[Entity]) RemainingRetention(
+	ctx context.Context,
+	key string,
+) (time.Duration, error) {
+	defer c.observe(OperationRemainingRetention, time.Now())
+	retention, err := c.cache.RemainingRetention(ctx, key)
+	return retention, c.observeError(OperationRemainingRetention, err)
+}
+
+func (c *instrumentedCache[Entity]) Touch(
+	ctx context.Context,
+	key string,
+	retention time.Duration,
+) (bool, error) {
+	defer c.observe(OperationTouch, time.Now())
+	touched, err := c.cache.Touch(ctx, key, retention)
+	return touched, c.observeError(OperationTouch, err)
+}
+
+func (c *instrumentedCache[Entity]) Watch(
+	ctx context.Context,
+) (<-chan Event[Entity], error) {
+	watchable, ok := c.cache.(WatchableCache[Entity])
+	if !ok {
+		return nil, c.observeError(OperationWatch, NewErrWatchDisabled(c.name))
+	}
+	events, err := watchable.Watch(ctx)
+	return events, c.observeError(OperationWatch, err)
+}
+
+// WithNamespace instruments the view under the name of this cache, so namespaces are reported in aggregate.
+func (c *instrumentedCache[Entity]) WithNamespace(
+	namespace string,
+) Cache[Entity] {
+	return NewInstrumentedCache(c.name, c.cache.WithNamespace(namespace), c.observer)
+}
+
+func (c *instrumentedCache[Entity]) Namespaces(
+	ctx context.Context,
+) ([]string, error) {
+	defer c.observe(OperationNamespaces, time.Now())
+	lister, ok := c.cache.(NamespaceLister)
+	if !ok {
+		return nil, c.observeError(OperationNamespaces, NewErrUnsupportedOperation(string(OperationNamespaces)))
+	}
+	namespaces, err := lister.Namespaces(ctx)
+	return namespaces, c.observeError(OperationNamespaces, err)
+}
+
+func (c *instrumentedCache[Entity]) observe(operation Operation, start time.Time) {
+	c.observer.OnOperation(c.name, operation, time.Since(start))
+}
+
+func (c *instrumentedCache[Entity]) observeError(operation Operation, err error) error {
+	if err != nil {
+		c.observer.OnError(c.name, operation, err)
+	}
+	return err
+}
+
+// batchKeyErrors returns the per-key failures of a batch operation, ok is false if the whole batch failed
+func batchKeyErrors(err error) (keyErrors map[string]error, ok bool) {
+	if err == nil {
+		return nil, true
+	}
+	var batchErr ErrBatch
+	if errors.As(err, &batchErr) {
+		return batchErr.KeyErrors(), true
+	}
+	return nil, false
+}
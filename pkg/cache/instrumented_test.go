@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+// instrumentedTestRuns makes the cache names unique across repeated runs, as expvar maps live as long as the process
+var instrumentedTestRuns atomic.Int64
+
+func TestInstrumentedCacheReportsToExpvar(t *testing.T) {
+	ctx := context.TODO()
+	observer, err := NewExpvarObserver("cache_test_instrumented")
+	require.Nil(t, err)
+	cacheName := fmt.Sprintf("%s-%d", t.Name(), instrumentedTestRuns.Add(1))
+	cut := NewInstrumentedCache[string](cacheName, NewMemoryCache[string](), observer)
+
+	require.Nil(t, cut.Set(ctx, "key1", "value1", time.Minute))
+	_, err = cut.Get(ctx, "key1")
+	require.Nil(t, err)
+	_, err = cut.Get(ctx, "key2")
+	require.Nil(t, err)
+	_, err = cut.GetMany(ctx, []string{"key1", "key2", "key3"})
+	require.Nil(t, err)
+	stored, err := cut.SetIfAbsent(ctx, "key1", "value2", time.Minute)
+	require.Nil(t, err)
+	require.False(t, stored)
+	require.Nil(t, cut.RemoveMany(ctx, []string{"key1", "key2"}))
+
+	metrics := expvar.Get("cache_test_instrumented").(*expvar.Map).Get(cacheName).(*expvar.Map)
+	require.Equal(t, "2", metrics.Get("hits").String())
+	require.Equal(t, "3", metrics.Get("misses").String())
+	require.Equal(t, "1", metrics.Get("sets").String())
+	require.Equal(t, "2", metrics.Get("removals").String())
+	require.Equal(t, "2", metrics.Get("get_count").String())
+	require.Nil(t, metrics.Get("errors"))
+
+	// the same name reuses the published map, also when observers are created concurrently
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, innerErr := NewExpvarObserver("cache_test_concurrent")
+			require.Nil(t, innerErr)
+		}()
+	}
+	wg.Wait()
+	_, err = NewExpvarObserver("cache_test_instrumented")
+	require.Nil(t, err)
+
+	if expvar.Get("cache_test_int") == nil {
+		expvar.NewInt("cache_test_int")
+	}
+	_, err = NewExpvarObserver("cache_test_int")
+	require.True(t, errors.As(err, &ErrExpvarNameTaken{}))
+}
+
+func TestInstrumentedCacheWatchUnsupported(t *testing.T) {
+	unwatchable := struct{ Cache[string] }{NewMemoryCache[string]()}
+	observer, err := NewExpvarObserver("cache_test_watch")
+	require.Nil(t, err)
+	cut := NewInstrumentedCache[string]("demo", unwatchable, observer)
+	_, err = cut.(WatchableCache[string]).Watch(context.TODO())
+	require.NotNil(t, err)
+}
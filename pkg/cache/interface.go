@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"iter"
 	"time"
 
 	"golang.org/x/net/context"
@@ -19,6 +20,13 @@ type Cache[Entity any] interface {
 		ctx context.Context,
 	) ([]Entity, error)
 
+	// All streams the entries of the cache without loading all of them into memory at once.
+	// Iteration stops after the first yielded error. Implementations backed by cursor-based
+	// scans may yield a key more than once if the cache is modified during iteration.
+	All(
+		ctx context.Context,
+	) iter.Seq2[Entry[Entity], error]
+
 	Set(
 		ctx context.Context,
 		key string,
@@ -36,8 +44,116 @@ type Cache[Entity any] interface {
 		key string,
 	) error
 
+	// GetMany returns the values of all given keys that are present in the cache.
+	// Failures of single keys are reported through an ErrBatch and do not affect the other keys.
+	GetMany(
+		ctx context.Context,
+		keys []string,
+	) (map[string]Entity, error)
+
+	// SetMany stores all given entries with the same retention.
+	// Failures of single keys are reported through an ErrBatch and do not affect the other keys.
+	SetMany(
+		ctx context.Context,
+		entries map[string]Entity,
+		retention time.Duration,
+	) error
+
+	// RemoveMany removes all given keys.
+	// Failures of single keys are reported through an ErrBatch and do not affect the other keys.
+	RemoveMany(
+		ctx context.Context,
+		keys []string,
+	) error
+
+	// Clear removes all entries of this cache instance including those of its namespaces,
+	// entries of other caches sharing the same backend are kept.
+	Clear(
+		ctx context.Context,
+	) error
+
+	// SetIfAbsent stores value only if key is not present and reports whether it was stored.
+	SetIfAbsent(
+		ctx context.Context,
+		key string,
+		value Entity,
+		retention time.Duration,
+	) (bool, error)
+
+	// CompareAndSwap atomically replaces the value of key with newValue if the current value equals
+	// oldValue and reports whether it was replaced. Values are compared by their JSON representation.
+	CompareAndSwap(
+		ctx context.Context,
+		key string,
+		oldValue Entity,
+		newValue Entity,
+		retention time.Duration,
+	) (bool, error)
+
+	// RemoveIfEquals atomically removes key if its current value equals value and reports whether it
+	// was removed. Values are compared by their JSON representation.
+	RemoveIfEquals(
+		ctx context.Context,
+		key string,
+		value Entity,
+	) (bool, error)
+
 	RemainingRetention(
 		ctx context.Context,
 		key string,
 	) (time.Duration, error)
+
+	// Touch resets the retention of key to retention counted from now without modifying its value and
+	// reports whether key was present. A non-positive retention keeps the entry until it is removed.
+	Touch(
+		ctx context.Context,
+		key string,
+		retention time.Duration,
+	) (bool, error)
+
+	// WithNamespace returns a view of the cache scoped to the child namespace, sharing the backend and its
+	// connections. Entries of a view are separated from those of its parent and siblings, views can be
+	// nested to build hierarchies.
+	WithNamespace(
+		namespace string,
+	) Cache[Entity]
+}
+
+// NamespaceLister is implemented by caches that can enumerate the namespaces created below them.
+type NamespaceLister interface {
+	// Namespaces returns the direct child namespaces, nested namespaces are listed by their parent
+	Namespaces(
+		ctx context.Context,
+	) ([]string, error)
+}
+
+type Entry[Entity any] struct {
+	Key   string
+	Value Entity
+}
+
+// Counter maintains numeric values that are modified atomically. With a retention, a counter is
+// reset once the retention has passed since it was first modified, which allows fixed-window quotas.
+type Counter interface {
+	Increment(
+		ctx context.Context,
+		key string,
+		delta int64,
+	) (int64, error)
+
+	Decrement(
+		ctx context.Context,
+		key string,
+		delta int64,
+	) (int64, error)
+
+	GetCount(
+		ctx context.Context,
+		key string,
+	) (int64, error)
+
+	Reset(
+		ctx context.Context,
+		key string,
+	) error
 }
@@ -0,0 +1,236 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sync"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/redis/rueidis"
+)
+
+// Invalidator distributes the keys of changed entries among all instances sharing a remote cache.
+type Invalidator interface {
+	Publish(
+		ctx context.Context,
+		keys ...string,
+	) error
+
+	// PublishClear notifies all subscribers that every entry, including those of nested namespaces, has been removed.
+	PublishClear(
+		ctx context.Context,
+	) error
+
+	// Subscribe blocks until ctx is done or the subscription fails, invoking callback for every
+	// published message including those published by the subscribing instance itself.
+	// Messages published in nested namespaces are delivered as well.
+	Subscribe(
+		ctx context.Context,
+		callback func(invalidation Invalidation),
+	) error
+
+	// WithNamespace returns an invalidator publishing within the child namespace, sharing the transport.
+	WithNamespace(
+		namespace string,
+	) Invalidator
+}
+
+// Invalidation is a message received by a subscriber of an Invalidator.
+type Invalidation struct {
+	// Namespace is the path of the namespace the message was published in, relative to the subscriber
+	Namespace []string
+	Keys      []string
+	// All reports that the namespace was cleared, Keys is empty then
+	All bool
+}
+
+type invalidationMessage struct {
+	Namespace []string `json:"namespace,omitempty"`
+	Keys      []string `json:"keys"`
+	All       bool     `json:"all,omitempty"`
+}
+
+// relativeTo returns the invalidation as seen by a subscriber in namespace, false if it was published outside of it
+func (m invalidationMessage) relativeTo(namespace []string) (Invalidation, bool) {
+	if len(m.Namespace) < len(namespace) || !slices.Equal(m.Namespace[:len(namespace)], namespace) {
+		return Invalidation{}, false
+	}
+	return Invalidation{
+		Namespace: m.Namespace[len(namespace):],
+		Keys:      m.Keys,
+		All:       m.All,
+	}, true
+}
+
+type memorySubscriber struct {
+	namespace []string
+	callback  func(invalidation Invalidation)
+}
+
+type memorySubscribers struct {
+	mu          sync.RWMutex
+	subscribers map[int]memorySubscriber
+	nextID      int
+}
+
+type memoryInvalidator struct {
+	subscribers *memorySubscribers
+	namespace   []string
+}
+
+// NewMemoryInvalidator creates an invalidator that only distributes keys within the current process.
+func NewMemoryInvalidator() Invalidator {
+	return &memoryInvalidator{
+		subscribers: &memorySubscribers{
+			subscribers: make(map[int]memorySubscriber),
+		},
+	}
+}
+
+func (i *memoryInvalidator) Publish(
+	_ context.Context,
+	keys ...string,
+) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	i.notify(invalidationMessage{Namespace: i.namespace, Keys: keys})
+	return nil
+}
+
+func (i *memoryInvalidator) PublishClear(
+	_ context.Context,
+) error {
+	i.notify(invalidationMessage{Namespace: i.namespace, All: true})
+	return nil
+}
+
+func (i *memoryInvalidator) notify(message invalidationMessage) {
+	i.subscribers.mu.RLock()
+	defer i.subscribers.mu.RUnlock()
+	for _, subscriber := range i.subscribers.subscribers {
+		if invalidation, ok := message.relativeTo(subscriber.namespace); ok {
+			subscriber.callback(invalidation)
+		}
+	}
+}
+
+func (i *memoryInvalidator) Subscribe(
+	ctx context.Context,
+	callback func(invalidation Invalidation),
+) error {
+	s := i.subscribers
+	s.mu.Lock()
+	id := s.nextID
+	s.nextID++
+	s.subscribers[id] = memorySubscriber{namespace: i.namespace, callback: callback}
+	s.mu.Unlock()
+
+	<-ctx.Done()
+
+	s.mu.Lock()
+	delete(s.subscribers, id)
+	s.mu.Unlock()
+	return ctx.Err()
+}
+
+func (i *memoryInvalidator) WithNamespace(
+	namespace string,
+) Invalidator {
+	return &memoryInvalidator{
+		subscribers: i.subscribers,
+		namespace:   append(slices.Clone(i.namespace), namespace),
+	}
+}
+
+type redisInvalidator struct {
+	client    rueidis.Client
+	channel   string
+	namespace []string
+}
+
+func NewRedisInvalidator(
+	redisURL string,
+	redisPassword string,
+	channel string,
+) (Invalidator, error) {
+	client, err := rueidis.NewClient(rueidis.ClientOption{
+		InitAddress: []string{redisURL},
+		Password:    redisPassword,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewRedisInvalidatorFromClient(client, channel), nil
+}
+
+// NewRedisInvalidatorFromClient creates an invalidator on top of an existing, possibly shared client.
+func NewRedisInvalidatorFromClient(
+	client rueidis.Client,
+	channel string,
+) Invalidator {
+	return &redisInvalidator{
+		client:  client,
+		channel: channel,
+	}
+}
+
+func (i *redisInvalidator) Publish(
+	ctx context.Context,
+	keys ...string,
+) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return i.publish(ctx, invalidationMessage{Namespace: i.namespace, Keys: keys})
+}
+
+func (i *redisInvalidator) PublishClear(
+	ctx context.Context,
+) error {
+	return i.publish(ctx, invalidationMessage{Namespace: i.namespace, All: true})
+}
+
+func (i *redisInvalidator) publish(
+	ctx context.Context,
+	message invalidationMessage,
+) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return i.client.Do(ctx, i.client.B().Publish().Channel(i.channel).Message(string(data)).Build()).Error()
+}
+
+// Subscribe receives the messages of all namespaces through the single channel, discarding those
+// published outside of the namespace of this invalidator.
+func (i *redisInvalidator) Subscribe(
+	ctx context.Context,
+	callback func(invalidation Invalidation),
+) error {
+	return i.client.Receive(ctx, i.client.B().Subscribe().Channel(i.channel).Build(), func(msg rueidis.PubSubMessage) {
+		var message invalidationMessage
+		if err := json.Unmarshal([]byte(msg.Message), &message); err != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err).
+				Printf("failed to decode invalidation message on channel '%s'", i.channel)
+			return
+		}
+		if !message.All && len(message.Keys) == 0 {
+			return
+		}
+		if invalidation, ok := message.relativeTo(i.namespace); ok {
+			callback(invalidation)
+		}
+	})
+}
+
+func (i *redisInvalidator) WithNamespace(
+	namespace string,
+) Invalidator {
+	return &redisInvalidator{
+		client:    i.client,
+		channel:   i.channel,
+		namespace: append(slices.Clone(i.namespace), namespace),
+	}
+}
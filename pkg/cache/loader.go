@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Roshick/go-autumn-synchronisation/pkg/locker"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+)
+
+type LoadFunc[Entity any] func(ctx context.Context, key string) (Entity, error)
+
+// Loader populates a cache on misses. Concurrent misses of the same key within the process share a
+// single loader call, an optional locker additionally collapses them across all processes.
+type Loader[Entity any] struct {
+	key    string
+	cache  Cache[Entity]
+	locker locker.Locker
+
+	mu    sync.Mutex
+	calls map[string]*loadCall[Entity]
+}
+
+type loadCall[Entity any] struct {
+	done  chan struct{}
+	value Entity
+	err   error
+}
+
+func NewLoader[Entity any](
+	key string,
+	cache Cache[Entity],
+	locker locker.Locker,
+) *Loader[Entity] {
+	return &Loader[Entity]{
+		key:    key,
+		cache:  cache,
+		locker: locker,
+		calls:  make(map[string]*loadCall[Entity]),
+	}
+}
+
+func (l *Loader[Entity]) GetOrLoad(
+	ctx context.Context,
+	key string,
+	loader LoadFunc[Entity],
+	retention time.Duration,
+) (Entity, error) {
+	if value, err := l.cache.Get(ctx, key); err != nil {
+		return *new(Entity), err
+	} else if value != nil {
+		return *value, nil
+	}
+
+	l.mu.Lock()
+	if call, ok := l.calls[key]; ok {
+		l.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.value, call.err
+		case <-ctx.Done():
+			return *new(Entity), ctx.Err()
+		}
+	}
+	call := &loadCall[Entity]{done: make(chan struct{})}
+	l.calls[key] = call
+	l.mu.Unlock()
+
+	// a panicking loader is reported to the waiting callers as error before it is propagated to this one
+	defer func() {
+		recovered := recover()
+		if recovered != nil {
+			call.value, call.err = *new(Entity), NewErrLoadPanicked(key, recovered)
+		}
+		l.mu.Lock()
+		delete(l.calls, key)
+		l.mu.Unlock()
+		close(call.done)
+		if recovered != nil {
+			panic(recovered)
+		}
+	}()
+	call.value, call.err = l.load(ctx, key, loader, retention)
+	return call.value, call.err
+}
+
+func (l *Loader[Entity]) load(
+	ctx context.Context,
+	key string,
+	loader LoadFunc[Entity],
+	retention time.Duration,
+) (Entity, error) {
+	if l.locker != nil {
+		lCtx, cancel, err := l.locker.ObtainLock(ctx, l.lockerKey(key))
+		if err != nil {
+			return *new(Entity), err
+		}
+		defer cancel()
+		ctx = lCtx
+
+		// another process might have loaded the value while waiting for the lock
+		if value, innerErr := l.cache.Get(ctx, key); innerErr != nil {
+			return *new(Entity), innerErr
+		} else if value != nil {
+			return *value, nil
+		}
+	}
+
+	value, err := loader(ctx, key)
+	if err != nil {
+		return *new(Entity), err
+	}
+	if err = l.cache.Set(ctx, key, value, retention); err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).
+			Printf("failed to cache loaded %s value '%s'", l.key, key)
+	}
+	return value, nil
+}
+
+func (l *Loader[Entity]) lockerKey(key string) string {
+	return fmt.Sprintf("%s-%s-loader", l.key, key)
+}
@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Roshick/go-autumn-synchronisation/pkg/locker"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func TestLoaderCollapsesConcurrentMisses(t *testing.T) {
+	ctx := context.TODO()
+	cut := NewLoader("demo", NewMemoryCache[demoEntity](), locker.NewMemoryLocker())
+
+	var calls atomic.Int64
+	release := make(chan struct{})
+	loader := func(_ context.Context, key string) (demoEntity, error) {
+		calls.Add(1)
+		<-release
+		return demoEntity{Value1: key}, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make(chan demoEntity, 10)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := cut.GetOrLoad(ctx, "key1", loader, time.Hour)
+			require.NoError(t, err)
+			results <- value
+		}()
+	}
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	require.Equal(t, int64(1), calls.Load())
+	for value := range results {
+		require.Equal(t, demoEntity{Value1: "key1"}, value)
+	}
+
+	value, err := cut.GetOrLoad(ctx, "key1", loader, time.Hour)
+	require.Nil(t, err)
+	require.Equal(t, demoEntity{Value1: "key1"}, value)
+	require.Equal(t, int64(1), calls.Load())
+}
+
+func TestLoaderDoesNotCacheErrors(t *testing.T) {
+	ctx := context.TODO()
+	cache := NewMemoryCache[demoEntity]()
+	cut := NewLoader("demo", cache, nil)
+
+	loadErr := errors.New("upstream unavailable")
+	_, err := cut.GetOrLoad(ctx, "key1", func(_ context.Context, _ string) (demoEntity, error) {
+		return demoEntity{}, loadErr
+	}, time.Hour)
+	require.ErrorIs(t, err, loadErr)
+
+	got, err := cache.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.Nil(t, got)
+}
+
+func TestLoaderReportsPanicsToWaitingCallers(t *testing.T) {
+	ctx := context.TODO()
+	cut := NewLoader("demo", NewMemoryCache[demoEntity](), nil)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	leaderPanic := make(chan any, 1)
+	go func() {
+		defer func() { leaderPanic <- recover() }()
+		_, _ = cut.GetOrLoad(ctx, "key1", func(_ context.Context, _ string) (demoEntity, error) {
+			close(started)
+			<-release
+			panic("loader failed")
+		}, time.Hour)
+	}()
+	<-started
+
+	followerErr := make(chan error, 1)
+	go func() {
+		_, err := cut.GetOrLoad(ctx, "key1", func(_ context.Context, key string) (demoEntity, error) {
+			return demoEntity{Value1: key}, nil
+		}, time.Hour)
+		followerErr <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	require.Equal(t, "loader failed", <-leaderPanic)
+	require.True(t, errors.As(<-followerErr, &ErrLoadPanicked{}))
+}
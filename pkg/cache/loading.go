@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+)
+
+// LoadingCache serves values loaded into a cache, refreshing them in the background once they are
+// older than the soft TTL and blocking on the loader only once they are older than the hard TTL.
+// The age of a value is inferred from its remaining retention assuming it was written with the hard TTL,
+// so values written to the underlying cache by other means or with other retentions are misjudged.
+// Values without retention are never refreshed.
+type LoadingCache[Entity any] struct {
+	ctx    context.Context
+	cache  Cache[Entity]
+	load   LoadFunc[Entity]
+	loader *Loader[Entity]
+	config LoadingCacheConfig
+
+	mu         sync.Mutex
+	refreshing map[string]bool
+}
+
+type LoadingCacheConfig struct {
+	// SoftTTL is the age after which reads trigger a background refresh while still returning the stale value,
+	// defaults to the one of CreateDefaultLoadingCacheConfig if not positive. It has to be below HardTTL,
+	// otherwise values would expire before being refreshed, so larger values are reduced to half of HardTTL.
+	SoftTTL time.Duration
+	// HardTTL is the retention of loaded values, reads of missing or expired values block on the loader.
+	// Defaults to the one of CreateDefaultLoadingCacheConfig if not positive.
+	HardTTL time.Duration
+	// OnRefreshError is called if a background refresh fails, the stale value is kept in that case.
+	// Panics of the loader during a refresh are reported as ErrLoadPanicked.
+	OnRefreshError func(ctx context.Context, key string, err error)
+}
+
+func CreateDefaultLoadingCacheConfig() LoadingCacheConfig {
+	return LoadingCacheConfig{
+		SoftTTL: 1 * time.Minute,
+		HardTTL: 10 * time.Minute,
+	}
+}
+
+// NewLoadingCache creates a loading cache whose background refreshes run with ctx.
+// Concurrent blocking loads are collapsed as described for NewLoader.
+func NewLoadingCache[Entity any](
+	ctx context.Context,
+	key string,
+	cache Cache[Entity],
+	load LoadFunc[Entity],
+	config *LoadingCacheConfig,
+) *LoadingCache[Entity] {
+	var vConfig LoadingCacheConfig
+	if config != nil {
+		vConfig = *config
+	} else {
+		vConfig = CreateDefaultLoadingCacheConfig()
+	}
+	if vConfig.HardTTL <= 0 {
+		vConfig.HardTTL = CreateDefaultLoadingCacheConfig().HardTTL
+	}
+	if vConfig.SoftTTL <= 0 {
+		vConfig.SoftTTL = CreateDefaultLoadingCacheConfig().SoftTTL
+	}
+	if vConfig.SoftTTL >= vConfig.HardTTL {
+		vConfig.SoftTTL = vConfig.HardTTL / 2
+	}
+
+	return &LoadingCache[Entity]{
+		ctx:        ctx,
+		cache:      cache,
+		load:       load,
+		loader:     NewLoader(key, cache, nil),
+		config:     vConfig,
+		refreshing: make(map[string]bool),
+	}
+}
+
+func (c *LoadingCache[Entity]) Get(
+	ctx context.Context,
+	key string,
+) (Entity, error) {
+	value, err := c.cache.Get(ctx, key)
+	if err != nil {
+		return *new(Entity), err
+	}
+	if value == nil {
+		return c.loader.GetOrLoad(ctx, key, c.load, c.config.HardTTL)
+	}
+
+	retention, err := c.cache.RemainingRetention(ctx, key)
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).
+			Printf("failed to determine age of '%s', serving cached value", key)
+		return *value, nil
+	}
+	if retention != math.MaxInt64 && c.config.HardTTL-retention >= c.config.SoftTTL {
+		c.refresh(key)
+	}
+	return *value, nil
+}
+
+// refresh reloads the value of key in the background unless a refresh of key is already running
+func (c *LoadingCache[Entity]) refresh(key string) {
+	c.mu.Lock()
+	if c.refreshing[key] {
+		c.mu.Unlock()
+		return
+	}
+	c.refreshing[key] = true
+	c.mu.Unlock()
+
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			delete(c.refreshing, key)
+			c.mu.Unlock()
+		}()
+
+		if err := c.reload(key); err != nil {
+			aulogging.Logger.Ctx(c.ctx).Warn().WithErr(err).
+				Printf("failed to refresh '%s', keeping stale value", key)
+			if c.config.OnRefreshError != nil {
+				c.config.OnRefreshError(c.ctx, key, err)
+			}
+		}
+	}()
+}
+
+// reload loads the value of key into the cache, a panicking loader is reported as ErrLoadPanicked as
+// there is no caller to propagate the panic to
+func (c *LoadingCache[Entity]) reload(key string) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = NewErrLoadPanicked(key, recovered)
+		}
+	}()
+	value, err := c.load(c.ctx, key)
+	if err != nil {
+		return err
+	}
+	return c.cache.Set(c.ctx, key, value, c.config.HardTTL)
+}
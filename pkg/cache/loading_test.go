@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func TestLoadingCacheRefreshesStaleValues(t *testing.T) {
+	ctx := context.TODO()
+	var version atomic.Int64
+	cut := NewLoadingCache(ctx, "demo", NewMemoryCache[int64](), func(_ context.Context, _ string) (int64, error) {
+		return version.Add(1), nil
+	}, &LoadingCacheConfig{
+		SoftTTL: 20 * time.Millisecond,
+		HardTTL: time.Hour,
+	})
+
+	value, err := cut.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.Equal(t, int64(1), value)
+
+	value, err = cut.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.Equal(t, int64(1), value)
+
+	time.Sleep(30 * time.Millisecond)
+	value, err = cut.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.Equal(t, int64(1), value)
+
+	require.Eventually(t, func() bool {
+		value, err = cut.Get(ctx, "key1")
+		return err == nil && value == 2
+	}, time.Second, time.Millisecond)
+}
+
+func TestLoadingCacheKeepsStaleValueOnRefreshError(t *testing.T) {
+	ctx := context.TODO()
+	loadErr := errors.New("upstream unavailable")
+	var failing atomic.Bool
+	refreshErrors := make(chan error, 1)
+	cut := NewLoadingCache(ctx, "demo", NewMemoryCache[string](), func(_ context.Context, key string) (string, error) {
+		if failing.Load() {
+			return "", loadErr
+		}
+		return key, nil
+	}, &LoadingCacheConfig{
+		SoftTTL: 10 * time.Millisecond,
+		HardTTL: time.Hour,
+		OnRefreshError: func(_ context.Context, _ string, err error) {
+			refreshErrors <- err
+		},
+	})
+
+	value, err := cut.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.Equal(t, "key1", value)
+
+	failing.Store(true)
+	time.Sleep(20 * time.Millisecond)
+	value, err = cut.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.Equal(t, "key1", value)
+	require.ErrorIs(t, <-refreshErrors, loadErr)
+
+	value, err = cut.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.Equal(t, "key1", value)
+}
+
+func TestLoadingCacheReportsPanickingRefresh(t *testing.T) {
+	ctx := context.TODO()
+	var panicking atomic.Bool
+	refreshErrors := make(chan error, 1)
+	cut := NewLoadingCache(ctx, "demo", NewMemoryCache[string](), func(_ context.Context, key string) (string, error) {
+		if panicking.Load() {
+			panic("upstream broken")
+		}
+		return key, nil
+	}, &LoadingCacheConfig{
+		SoftTTL: 10 * time.Millisecond,
+		HardTTL: time.Hour,
+		OnRefreshError: func(_ context.Context, _ string, err error) {
+			refreshErrors <- err
+		},
+	})
+
+	_, err := cut.Get(ctx, "key1")
+	require.Nil(t, err)
+
+	panicking.Store(true)
+	time.Sleep(20 * time.Millisecond)
+	value, err := cut.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.Equal(t, "key1", value)
+	require.ErrorAs(t, <-refreshErrors, &ErrLoadPanicked{})
+}
+
+func TestLoadingCacheDefaultsSoftTTL(t *testing.T) {
+	ctx := context.TODO()
+	var loads atomic.Int64
+	cut := NewLoadingCache(ctx, "demo", NewMemoryCache[int64](), func(_ context.Context, _ string) (int64, error) {
+		return loads.Add(1), nil
+	}, &LoadingCacheConfig{
+		HardTTL: time.Hour,
+	})
+
+	for i := 0; i < 3; i++ {
+		value, err := cut.Get(ctx, "key1")
+		require.Nil(t, err)
+		require.Equal(t, int64(1), value)
+	}
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(t, int64(1), loads.Load())
+}
+
+func TestLoadingCacheClampsSoftTTL(t *testing.T) {
+	ctx := context.TODO()
+	load := func(_ context.Context, _ string) (int64, error) {
+		return 1, nil
+	}
+
+	cut := NewLoadingCache(ctx, "demo", NewMemoryCache[int64](), load, &LoadingCacheConfig{
+		SoftTTL: time.Hour,
+		HardTTL: time.Minute,
+	})
+	require.Equal(t, 30*time.Second, cut.config.SoftTTL)
+
+	// the default soft TTL exceeds the configured hard TTL
+	cut = NewLoadingCache(ctx, "demo", NewMemoryCache[int64](), load, &LoadingCacheConfig{
+		HardTTL: 10 * time.Second,
+	})
+	require.Equal(t, 5*time.Second, cut.config.SoftTTL)
+}
@@ -2,8 +2,9 @@ package cache
 
 import (
 	"context"
-	"encoding/json"
+	"iter"
 	"math"
+	"sort"
 	"sync"
 	"time"
 
@@ -11,11 +12,128 @@ import (
 )
 
 type memoryCache[Entity any] struct {
-	store sync.Map
+	mu      sync.Mutex
+	entries map[string]*memoryEntry[Entity]
+	size    int64
+	policy  EvictionPolicy
+	values  memoryValues[Entity]
+	hub     *watchHub[Entity]
+	config  MemoryCacheConfig
+
+	childrenMu sync.Mutex
+	children   map[string]*memoryCache[Entity]
+}
+
+// memoryEntry holds either the encoded data or the value itself, depending on the memoryValues of the cache
+type memoryEntry[Entity any] struct {
+	data      []byte
+	value     Entity
+	valueSize int64
+	expiresAt time.Time
+	createdAt time.Time
+	updatedAt time.Time
+	version   int64
+}
+
+type MemoryCacheConfig struct {
+	// JanitorInterval is the period in which expired entries are removed in the background,
+	// a non-positive value disables the janitor and expired entries are only removed on access
+	JanitorInterval time.Duration
+	// MaxEntries bounds the number of entries, a non-positive value means unbounded
+	MaxEntries int
+	// MaxBytes bounds the summed size of all keys and encoded values, a non-positive value means unbounded
+	MaxBytes int64
+	// EvictionPolicy creates the policy of a bounded cache, defaults to NewLRUEvictionPolicy
+	EvictionPolicy func() EvictionPolicy
+	// OnEviction is called outside the cache lock for every entry dropped due to capacity or expiry
+	OnEviction func(key string, reason EvictionReason)
+	// Codec encodes the stored values, defaults to NewJSONCodec
+	Codec Codec
+	// WatchBufferSize is the number of events buffered per watcher before it is dropped
+	WatchBufferSize int
+	// SlidingRetention, if positive, resets the retention of every entry read by Get or GetMany to the given
+	// duration, so that entries only expire after a period of inactivity
+	SlidingRetention time.Duration
+}
+
+// memoryValues converts values into their representation within memory entries and back. Both directions
+// protect stored values from modifications by callers.
+type memoryValues[Entity any] interface {
+	store(entry *memoryEntry[Entity], value Entity) error
+
+	load(entry *memoryEntry[Entity]) (*Entity, error)
+}
+
+// codecValues stores the values encoded by codec
+type codecValues[Entity any] struct {
+	codec Codec
+}
+
+type eviction struct {
+	key    string
+	reason EvictionReason
+}
+
+func CreateDefaultMemoryCacheConfig() MemoryCacheConfig {
+	return MemoryCacheConfig{
+		JanitorInterval: 1 * time.Minute,
+		Codec:           NewJSONCodec(),
+		WatchBufferSize: 100,
+	}
 }
 
 func NewMemoryCache[Entity any]() Cache[Entity] {
-	return &memoryCache[Entity]{store: sync.Map{}}
+	return newMemoryCache[Entity](MemoryCacheConfig{}, newCodecValues[Entity](nil))
+}
+
+// NewMemoryCacheWithConfig creates a memory cache whose janitor runs until ctx is done.
+func NewMemoryCacheWithConfig[Entity any](
+	ctx context.Context,
+	config *MemoryCacheConfig,
+) Cache[Entity] {
+	var vConfig MemoryCacheConfig
+	if config != nil {
+		vConfig = *config
+	} else {
+		vConfig = CreateDefaultMemoryCacheConfig()
+	}
+
+	return startMemoryCache[Entity](ctx, vConfig, newCodecValues[Entity](vConfig.Codec))
+}
+
+// startMemoryCache creates a memory cache whose janitor runs until ctx is done
+func startMemoryCache[Entity any](
+	ctx context.Context,
+	config MemoryCacheConfig,
+	values memoryValues[Entity],
+) *memoryCache[Entity] {
+	c := newMemoryCache[Entity](config, values)
+	if config.JanitorInterval > 0 {
+		go c.janitor(ctx, config.JanitorInterval)
+	}
+	return c
+}
+
+func newMemoryCache[Entity any](config MemoryCacheConfig, values memoryValues[Entity]) *memoryCache[Entity] {
+	c := &memoryCache[Entity]{
+		entries:  make(map[string]*memoryEntry[Entity]),
+		values:   values,
+		config:   config,
+		children: make(map[string]*memoryCache[Entity]),
+	}
+	if config.WatchBufferSize > 0 {
+		c.hub = newWatchHub[Entity](config.WatchBufferSize)
+	} else {
+		c.hub = newWatchHub[Entity](CreateDefaultMemoryCacheConfig().WatchBufferSize)
+	}
+	if c.isBounded() {
+		if config.EvictionPolicy != nil {
+			c.policy = config.EvictionPolicy()
+		} else {
+			c.policy = NewLRUEvictionPolicy()
+		}
+	}
+	return c
 }
 
 func (c *memoryCache[Entity]) Entries(
@@ -23,17 +141,14 @@ func (c *memoryCache[Entity]) Entries(
 ) (map[string]Entity, error) {
 	aulogging.Logger.Ctx(ctx).Debug().Printf("fetching all entries from cache")
 	entries := make(map[string]Entity)
-	var firstError error
-	c.store.Range(func(key, value any) bool {
-		vPtr, err := unmarshal[Entity](value.(string))
+	for key, entry := range c.snapshot() {
+		vPtr, err := c.values.load(entry)
 		if err != nil {
-			firstError = err
-			return false
+			return entries, err
 		}
-		entries[key.(string)] = *vPtr
-		return true
-	})
-	return entries, firstError
+		entries[key] = *vPtr
+	}
+	return entries, nil
 }
 
 func (c *memoryCache[Entity]) Keys(
@@ -41,10 +156,9 @@ func (c *memoryCache[Entity]) Keys(
 ) ([]string, error) {
 	aulogging.Logger.Ctx(ctx).Debug().Printf("fetching all keys from cache")
 	keys := make([]string, 0)
-	c.store.Range(func(key, value any) bool {
-		keys = append(keys, key.(string))
-		return true
-	})
+	for key := range c.snapshot() {
+		keys = append(keys, key)
+	}
 	return keys, nil
 }
 
@@ -53,32 +167,43 @@ func (c *memoryCache[Entity]) Values(
 ) ([]Entity, error) {
 	aulogging.Logger.Ctx(ctx).Debug().Printf("fetching all values from cache")
 	values := make([]Entity, 0)
-	var firstError error
-	c.store.Range(func(key, value any) bool {
-		vPtr, err := unmarshal[Entity](value.(string))
+	for _, entry := range c.snapshot() {
+		vPtr, err := c.values.load(entry)
 		if err != nil {
-			firstError = err
-			return false
+			return values, err
 		}
 		values = append(values, *vPtr)
-		return true
-	})
-	return values, firstError
+	}
+	return values, nil
+}
+
+func (c *memoryCache[Entity]) All(
+	ctx context.Context,
+) iter.Seq2[Entry[Entity], error] {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("iterating all entries of cache")
+	return func(yield func(Entry[Entity], error) bool) {
+		for key, entry := range c.snapshot() {
+			vPtr, err := c.values.load(entry)
+			if err != nil {
+				yield(Entry[Entity]{}, err)
+				return
+			}
+			if !yield(Entry[Entity]{Key: key, Value: *vPtr}, nil) {
+				return
+			}
+		}
+	}
 }
 
 func (c *memoryCache[Entity]) Set(
 	ctx context.Context,
 	key string,
 	value Entity,
-	_ time.Duration,
+	retention time.Duration,
 ) error {
 	aulogging.Logger.Ctx(ctx).Debug().Printf("setting value of '%s' in cache", key)
-	jsonBytes, err := json.Marshal(value)
-	if err != nil {
-		return err
-	}
-	c.store.Store(key, string(jsonBytes))
-	return nil
+	_, err := c.write(key, value, retention, nil)
+	return err
 }
 
 func (c *memoryCache[Entity]) Get(
@@ -86,11 +211,36 @@ func (c *memoryCache[Entity]) Get(
 	key string,
 ) (*Entity, error) {
 	aulogging.Logger.Ctx(ctx).Debug().Printf("fetching value of '%s' from cache", key)
-	jsonString, ok := c.store.Load(key)
-	if !ok {
+	entry := c.read(key)
+	if entry == nil {
 		return nil, nil
 	}
-	return unmarshal[Entity](jsonString.(string))
+	return c.values.load(entry)
+}
+
+func (c *memoryCache[Entity]) GetWithMetadata(
+	ctx context.Context,
+	key string,
+) (*Entity, *EntryMetadata, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("fetching value and metadata of '%s' from cache", key)
+	entry := c.read(key)
+	if entry == nil {
+		return nil, nil, nil
+	}
+	value, err := c.values.load(entry)
+	if err != nil {
+		return nil, nil, err
+	}
+	metadata := &EntryMetadata{
+		CreatedAt:          entry.createdAt,
+		UpdatedAt:          entry.updatedAt,
+		Version:            entry.version,
+		RemainingRetention: math.MaxInt64,
+	}
+	if !entry.expiresAt.IsZero() {
+		metadata.RemainingRetention = max(time.Until(entry.expiresAt), 0)
+	}
+	return value, metadata, nil
 }
 
 func (c *memoryCache[Entity]) Remove(
@@ -98,25 +248,448 @@ func (c *memoryCache[Entity]) Remove(
 	key string,
 ) error {
 	aulogging.Logger.Ctx(ctx).Debug().Printf("removing value of '%s' from cache", key)
-	c.store.Delete(key)
+	c.mu.Lock()
+	_, evictions := c.load(key)
+	c.delete(key)
+	c.mu.Unlock()
+
+	c.notifyEvictions(evictions)
 	return nil
 }
 
+func (c *memoryCache[Entity]) Watch(
+	ctx context.Context,
+) (<-chan Event[Entity], error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("watching cache")
+	return c.hub.watch(ctx), nil
+}
+
+func (c *memoryCache[Entity]) GetMany(
+	ctx context.Context,
+	keys []string,
+) (map[string]Entity, error) {
+	values := make(map[string]Entity)
+	errs := make(map[string]error)
+	for _, key := range keys {
+		value, err := c.Get(ctx, key)
+		if err != nil {
+			errs[key] = err
+		} else if value != nil {
+			values[key] = *value
+		}
+	}
+	return values, batchError(errs)
+}
+
+func (c *memoryCache[Entity]) SetMany(
+	ctx context.Context,
+	entries map[string]Entity,
+	retention time.Duration,
+) error {
+	errs := make(map[string]error)
+	for key, value := range entries {
+		if err := c.Set(ctx, key, value, retention); err != nil {
+			errs[key] = err
+		}
+	}
+	return batchError(errs)
+}
+
+func (c *memoryCache[Entity]) RemoveMany(
+	ctx context.Context,
+	keys []string,
+) error {
+	errs := make(map[string]error)
+	for _, key := range keys {
+		if err := c.Remove(ctx, key); err != nil {
+			errs[key] = err
+		}
+	}
+	return batchError(errs)
+}
+
+func (c *memoryCache[Entity]) Clear(
+	ctx context.Context,
+) error {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("clearing cache")
+	c.mu.Lock()
+	for key := range c.entries {
+		c.delete(key)
+	}
+	c.mu.Unlock()
+
+	for _, child := range c.childCaches() {
+		if err := child.Clear(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *memoryCache[Entity]) SetIfAbsent(
+	ctx context.Context,
+	key string,
+	value Entity,
+	retention time.Duration,
+) (bool, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("setting value of '%s' in cache if absent", key)
+	return c.write(key, value, retention, func(previous *memoryEntry[Entity]) (bool, error) {
+		return previous == nil, nil
+	})
+}
+
+func (c *memoryCache[Entity]) CompareAndSwap(
+	ctx context.Context,
+	key string,
+	oldValue Entity,
+	newValue Entity,
+	retention time.Duration,
+) (bool, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("swapping value of '%s' in cache", key)
+	return c.write(key, newValue, retention, func(previous *memoryEntry[Entity]) (bool, error) {
+		return c.entryEquals(previous, oldValue)
+	})
+}
+
+func (c *memoryCache[Entity]) RemoveIfEquals(
+	ctx context.Context,
+	key string,
+	value Entity,
+) (bool, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("removing value of '%s' from cache if unchanged", key)
+	c.mu.Lock()
+	entry, evictions := c.load(key)
+	equal, err := c.entryEquals(entry, value)
+	if err == nil && equal {
+		c.delete(key)
+	}
+	c.mu.Unlock()
+
+	c.notifyEvictions(evictions)
+	return equal, err
+}
+
 func (c *memoryCache[Entity]) RemainingRetention(
 	_ context.Context,
 	key string,
 ) (time.Duration, error) {
-	_, ok := c.store.Load(key)
-	if !ok {
+	c.mu.Lock()
+	entry, evictions := c.load(key)
+	c.mu.Unlock()
+
+	c.notifyEvictions(evictions)
+	if entry == nil {
 		return 0, nil
 	}
-	return math.MaxInt64, nil
+	if entry.expiresAt.IsZero() {
+		return math.MaxInt64, nil
+	}
+	return max(time.Until(entry.expiresAt), 0), nil
+}
+
+func (c *memoryCache[Entity]) Touch(
+	ctx context.Context,
+	key string,
+	retention time.Duration,
+) (bool, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("touching value of '%s' in cache", key)
+	c.mu.Lock()
+	entry, evictions := c.load(key)
+	if entry != nil {
+		c.extend(key, entry, retention)
+		if c.policy != nil {
+			c.policy.Accessed(key)
+		}
+	}
+	c.mu.Unlock()
+
+	c.notifyEvictions(evictions)
+	return entry != nil, nil
+}
+
+// WithNamespace returns the child cache of namespace, creating it on first use. Children share the
+// configuration and janitor of their parent, bounds apply to every namespace separately.
+func (c *memoryCache[Entity]) WithNamespace(
+	namespace string,
+) Cache[Entity] {
+	c.childrenMu.Lock()
+	defer c.childrenMu.Unlock()
+	child, ok := c.children[namespace]
+	if !ok {
+		child = newMemoryCache[Entity](c.config, c.values)
+		c.children[namespace] = child
+	}
+	return child
+}
+
+func (c *memoryCache[Entity]) Namespaces(
+	_ context.Context,
+) ([]string, error) {
+	c.childrenMu.Lock()
+	defer c.childrenMu.Unlock()
+	namespaces := make([]string, 0, len(c.children))
+	for namespace := range c.children {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+func (c *memoryCache[Entity]) childCaches() []*memoryCache[Entity] {
+	c.childrenMu.Lock()
+	defer c.childrenMu.Unlock()
+	children := make([]*memoryCache[Entity], 0, len(c.children))
+	for _, child := range c.children {
+		children = append(children, child)
+	}
+	return children
+}
+
+// write stores value under key if condition, evaluated under the lock against the current entry, holds
+func (c *memoryCache[Entity]) write(
+	key string,
+	value Entity,
+	retention time.Duration,
+	condition func(previous *memoryEntry[Entity]) (bool, error),
+) (bool, error) {
+	now := time.Now()
+	entry := &memoryEntry[Entity]{createdAt: now, updatedAt: now, version: 1}
+	err := c.values.store(entry, value)
+	if err != nil {
+		return false, err
+	}
+	if retention > 0 {
+		entry.expiresAt = now.Add(retention)
+	}
+	if c.config.MaxBytes > 0 && entry.size(key) > c.config.MaxBytes {
+		return false, NewErrEntryTooLarge(key, entry.size(key), c.config.MaxBytes)
+	}
+
+	// load the stored value so that watchers cannot modify the caller's value
+	var event *Event[Entity]
+	if c.hub.hasWatchers() {
+		event = &Event[Entity]{Type: EventTypeAdded, Key: key}
+		if event.Value, err = c.values.load(entry); err != nil {
+			return false, err
+		}
+	}
+
+	c.mu.Lock()
+	previous, evictions := c.load(key)
+	written := true
+	if condition != nil {
+		written, err = condition(previous)
+	}
+	if err == nil && written {
+		if previous != nil {
+			entry.createdAt = previous.createdAt
+			entry.version = previous.version + 1
+		}
+		c.store(key, entry)
+		if event != nil {
+			if previous != nil {
+				event.Type = EventTypeUpdated
+			}
+			c.hub.publish(*event)
+		}
+		evictions = append(evictions, c.evict()...)
+	}
+	c.mu.Unlock()
+
+	c.notifyEvictions(evictions)
+	return written && err == nil, err
+}
+
+// entryEquals reports whether entry holds a value equal to value. The caller must hold the lock.
+func (c *memoryCache[Entity]) entryEquals(entry *memoryEntry[Entity], value Entity) (bool, error) {
+	if entry == nil {
+		return false, nil
+	}
+	current, err := c.values.load(entry)
+	if err != nil {
+		return false, err
+	}
+	return valuesEqual(*current, value), nil
+}
+
+// read returns the entry of key for a read access, extending its retention in sliding mode
+func (c *memoryCache[Entity]) read(key string) *memoryEntry[Entity] {
+	c.mu.Lock()
+	entry, evictions := c.load(key)
+	if entry != nil && c.config.SlidingRetention > 0 {
+		entry = c.extend(key, entry, c.config.SlidingRetention)
+	}
+	if entry != nil && c.policy != nil {
+		c.policy.Accessed(key)
+	}
+	c.mu.Unlock()
+
+	c.notifyEvictions(evictions)
+	return entry
+}
+
+// load returns the entry stored for key, expired entries are removed and reported as absent.
+// The caller must hold the lock.
+func (c *memoryCache[Entity]) load(key string) (*memoryEntry[Entity], []eviction) {
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	if entry.isExpired(time.Now()) {
+		c.delete(key)
+		return nil, []eviction{{key: key, reason: EvictionReasonExpired}}
+	}
+	return entry, nil
+}
+
+// store inserts or replaces the entry of key. The caller must hold the lock.
+func (c *memoryCache[Entity]) store(key string, entry *memoryEntry[Entity]) {
+	if previous, ok := c.entries[key]; ok {
+		c.size -= previous.size(key)
+	}
+	c.entries[key] = entry
+	c.size += entry.size(key)
+	if c.policy != nil {
+		c.policy.Added(key)
+	}
+}
+
+// extend replaces the expiry of the entry of key, which is copied as entries are read outside the lock.
+// The caller must hold the lock.
+func (c *memoryCache[Entity]) extend(
+	key string,
+	entry *memoryEntry[Entity],
+	retention time.Duration,
+) *memoryEntry[Entity] {
+	extended := *entry
+	extended.expiresAt = time.Time{}
+	if retention > 0 {
+		extended.expiresAt = time.Now().Add(retention)
+	}
+	c.entries[key] = &extended
+	return &extended
+}
+
+// delete removes the entry of key and informs watchers. The caller must hold the lock.
+func (c *memoryCache[Entity]) delete(key string) {
+	entry, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	delete(c.entries, key)
+	c.size -= entry.size(key)
+	if c.policy != nil {
+		c.policy.Removed(key)
+	}
+	c.hub.publish(Event[Entity]{Type: EventTypeRemoved, Key: key})
 }
 
-func unmarshal[Entity any](jsonString string) (*Entity, error) {
-	var value Entity
-	if err := json.Unmarshal([]byte(jsonString), &value); err != nil {
-		return nil, err
+// evict drops entries chosen by the eviction policy until the cache fits its bounds.
+// The caller must hold the lock.
+func (c *memoryCache[Entity]) evict() []eviction {
+	if c.policy == nil {
+		return nil
 	}
-	return &value, nil
+	evictions := make([]eviction, 0)
+	for c.isOverCapacity() {
+		key, ok := c.policy.Victim()
+		if !ok {
+			break
+		}
+		c.delete(key)
+		evictions = append(evictions, eviction{key: key, reason: EvictionReasonCapacity})
+	}
+	return evictions
+}
+
+func (c *memoryCache[Entity]) notifyEvictions(evictions []eviction) {
+	if c.config.OnEviction == nil {
+		return
+	}
+	for _, e := range evictions {
+		c.config.OnEviction(e.key, e.reason)
+	}
+}
+
+func (c *memoryCache[Entity]) isBounded() bool {
+	return c.config.MaxEntries > 0 || c.config.MaxBytes > 0
+}
+
+func (c *memoryCache[Entity]) isOverCapacity() bool {
+	return (c.config.MaxEntries > 0 && len(c.entries) > c.config.MaxEntries) ||
+		(c.config.MaxBytes > 0 && c.size > c.config.MaxBytes)
+}
+
+// snapshot copies all non-expired entries so that they can be processed without holding the lock
+func (c *memoryCache[Entity]) snapshot() map[string]*memoryEntry[Entity] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	entries := make(map[string]*memoryEntry[Entity], len(c.entries))
+	for key, entry := range c.entries {
+		if !entry.isExpired(now) {
+			entries[key] = entry
+		}
+	}
+	return entries
+}
+
+func (c *memoryCache[Entity]) janitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.removeExpired()
+		}
+	}
+}
+
+func (c *memoryCache[Entity]) removeExpired() {
+	c.mu.Lock()
+	now := time.Now()
+	evictions := make([]eviction, 0)
+	for key, entry := range c.entries {
+		if entry.isExpired(now) {
+			c.delete(key)
+			evictions = append(evictions, eviction{key: key, reason: EvictionReasonExpired})
+		}
+	}
+	c.mu.Unlock()
+
+	c.notifyEvictions(evictions)
+	for _, child := range c.childCaches() {
+		child.removeExpired()
+	}
+}
+
+func (e *memoryEntry[Entity]) isExpired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+func (e *memoryEntry[Entity]) size(key string) int64 {
+	return int64(len(key)) + e.valueSize
+}
+
+func newCodecValues[Entity any](codec Codec) codecValues[Entity] {
+	if codec == nil {
+		codec = NewJSONCodec()
+	}
+	return codecValues[Entity]{codec: codec}
+}
+
+func (v codecValues[Entity]) store(entry *memoryEntry[Entity], value Entity) error {
+	data, err := v.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	entry.data = data
+	entry.valueSize = int64(len(data))
+	return nil
+}
+
+func (v codecValues[Entity]) load(entry *memoryEntry[Entity]) (*Entity, error) {
+	return decode[Entity](v.codec, entry.data)
 }
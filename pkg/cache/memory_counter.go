@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type memoryCounter struct {
+	mu        sync.RWMutex
+	counters  map[string]*memoryCounterEntry
+	retention time.Duration
+}
+
+type memoryCounterEntry struct {
+	value     atomic.Int64
+	expiresAt time.Time
+}
+
+// NewMemoryCounter creates a counter whose values are reset after retention, a non-positive retention
+// keeps them forever. Expired counters are removed when they are accessed.
+func NewMemoryCounter(retention time.Duration) Counter {
+	return &memoryCounter{
+		counters:  make(map[string]*memoryCounterEntry),
+		retention: retention,
+	}
+}
+
+// Increment holds the lock for the whole read-modify-write, so that a concurrent Reset cannot orphan the entry
+func (c *memoryCounter) Increment(
+	_ context.Context,
+	key string,
+	delta int64,
+) (int64, error) {
+	now := time.Now()
+	c.mu.RLock()
+	if entry, ok := c.counters[key]; ok && !entry.isExpired(now) {
+		defer c.mu.RUnlock()
+		return entry.value.Add(delta), nil
+	}
+	c.mu.RUnlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entry(key, now).value.Add(delta), nil
+}
+
+func (c *memoryCounter) Decrement(
+	ctx context.Context,
+	key string,
+	delta int64,
+) (int64, error) {
+	return c.Increment(ctx, key, -delta)
+}
+
+func (c *memoryCounter) GetCount(
+	_ context.Context,
+	key string,
+) (int64, error) {
+	now := time.Now()
+	c.mu.RLock()
+	entry, ok := c.counters[key]
+	if !ok {
+		c.mu.RUnlock()
+		return 0, nil
+	}
+	if !entry.isExpired(now) {
+		defer c.mu.RUnlock()
+		return entry.value.Load(), nil
+	}
+	c.mu.RUnlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok = c.counters[key]; ok && entry.isExpired(now) {
+		delete(c.counters, key)
+	}
+	return 0, nil
+}
+
+func (c *memoryCounter) Reset(
+	_ context.Context,
+	key string,
+) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.counters, key)
+	return nil
+}
+
+// entry returns the current counter of key, starting a new one if it does not exist or has expired.
+// The caller must hold the write lock.
+func (c *memoryCounter) entry(key string, now time.Time) *memoryCounterEntry {
+	if entry, ok := c.counters[key]; ok && !entry.isExpired(now) {
+		return entry
+	}
+	entry := &memoryCounterEntry{}
+	if c.retention > 0 {
+		entry.expiresAt = now.Add(c.retention)
+	}
+	c.counters[key] = entry
+	return entry
+}
+
+func (e *memoryCounterEntry) isExpired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func TestMemoryCounter(t *testing.T) {
+	ctx := context.TODO()
+	cut := NewMemoryCounter(0)
+
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cut.Increment(ctx, "tenant1", 2)
+			require.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	count, err := cut.GetCount(ctx, "tenant1")
+	require.Nil(t, err)
+	require.Equal(t, int64(200), count)
+
+	count, err = cut.Decrement(ctx, "tenant1", 50)
+	require.Nil(t, err)
+	require.Equal(t, int64(150), count)
+
+	count, err = cut.GetCount(ctx, "tenant2")
+	require.Nil(t, err)
+	require.Zero(t, count)
+
+	require.Nil(t, cut.Reset(ctx, "tenant1"))
+	count, err = cut.GetCount(ctx, "tenant1")
+	require.Nil(t, err)
+	require.Zero(t, count)
+}
+
+func TestMemoryCounterRetention(t *testing.T) {
+	ctx := context.TODO()
+	cut := NewMemoryCounter(20 * time.Millisecond)
+
+	count, err := cut.Increment(ctx, "tenant1", 1)
+	require.Nil(t, err)
+	require.Equal(t, int64(1), count)
+	count, err = cut.Increment(ctx, "tenant1", 1)
+	require.Nil(t, err)
+	require.Equal(t, int64(2), count)
+
+	time.Sleep(30 * time.Millisecond)
+	count, err = cut.GetCount(ctx, "tenant1")
+	require.Nil(t, err)
+	require.Zero(t, count)
+	require.Empty(t, cut.(*memoryCounter).counters)
+
+	count, err = cut.Increment(ctx, "tenant1", 1)
+	require.Nil(t, err)
+	require.Equal(t, int64(1), count)
+}
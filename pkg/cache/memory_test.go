@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"math"
 	"testing"
 	"time"
 
@@ -128,6 +129,403 @@ func TestMemoryCacheDeepcopy(t *testing.T) {
 	require.EqualValues(t, e1, *got)
 }
 
+func TestMemoryCacheAll(t *testing.T) {
+	ctx := context.TODO()
+	cut := NewMemoryCache[demoEntity]()
+
+	for entry, err := range cut.All(ctx) {
+		require.Failf(t, "unexpected entry in empty cache", "%v %v", entry, err)
+	}
+
+	e1 := demoEntity{Value1: "value-for-v1"}
+	e2 := demoEntity{Value1: "e2-value-for-v1"}
+	require.Nil(t, cut.Set(ctx, "key1", e1, 0))
+	require.Nil(t, cut.Set(ctx, "key2", e2, 0))
+
+	entries := make(map[string]demoEntity)
+	for entry, err := range cut.All(ctx) {
+		require.Nil(t, err)
+		entries[entry.Key] = entry.Value
+	}
+	require.EqualValues(t, map[string]demoEntity{"key1": e1, "key2": e2}, entries)
+
+	count := 0
+	for range cut.All(ctx) {
+		count++
+		break
+	}
+	require.Equal(t, 1, count)
+}
+
+func TestMemoryCacheBatch(t *testing.T) {
+	ctx := context.TODO()
+	cut := NewMemoryCacheWithConfig[string](ctx, &MemoryCacheConfig{
+		MaxBytes: 50,
+	})
+
+	err := cut.SetMany(ctx, map[string]string{
+		"key1":  "value1",
+		"key2":  "value2",
+		"large": "this value does not fit into the cache, no matter what else is stored in the cache",
+	}, time.Hour)
+	var batchErr ErrBatch
+	require.ErrorAs(t, err, &batchErr)
+	require.Len(t, batchErr.KeyErrors(), 1)
+	require.ErrorAs(t, batchErr.KeyErrors()["large"], &ErrEntryTooLarge{})
+
+	values, err := cut.GetMany(ctx, []string{"key1", "key2", "key3"})
+	require.Nil(t, err)
+	require.EqualValues(t, map[string]string{"key1": "value1", "key2": "value2"}, values)
+
+	err = cut.RemoveMany(ctx, []string{"key1", "key3"})
+	require.Nil(t, err)
+
+	keys, err := cut.Keys(ctx)
+	require.Nil(t, err)
+	require.ElementsMatch(t, []string{"key2"}, keys)
+}
+
+func TestMemoryCacheConditionalWrites(t *testing.T) {
+	ctx := context.TODO()
+	cut := NewMemoryCache[demoEntity]()
+
+	e1 := demoEntity{Value1: "first", Value3: p(map[string]string{"mapkey1": "mapvalue1"})}
+	e2 := demoEntity{Value1: "second"}
+
+	stored, err := cut.SetIfAbsent(ctx, "key1", e1, 0)
+	require.Nil(t, err)
+	require.True(t, stored)
+	stored, err = cut.SetIfAbsent(ctx, "key1", e2, 0)
+	require.Nil(t, err)
+	require.False(t, stored)
+
+	swapped, err := cut.CompareAndSwap(ctx, "key1", e2, e2, 0)
+	require.Nil(t, err)
+	require.False(t, swapped)
+	swapped, err = cut.CompareAndSwap(ctx, "missing", e1, e2, 0)
+	require.Nil(t, err)
+	require.False(t, swapped)
+	swapped, err = cut.CompareAndSwap(ctx, "key1", e1, e2, 0)
+	require.Nil(t, err)
+	require.True(t, swapped)
+
+	got, err := cut.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.EqualValues(t, e2, *got)
+
+	removed, err := cut.RemoveIfEquals(ctx, "key1", e1)
+	require.Nil(t, err)
+	require.False(t, removed)
+	removed, err = cut.RemoveIfEquals(ctx, "key1", e2)
+	require.Nil(t, err)
+	require.True(t, removed)
+
+	got, err = cut.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.Nil(t, got)
+}
+
+func TestMemoryCacheWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	cut := NewMemoryCache[demoEntity]().(WatchableCache[demoEntity])
+
+	events, err := cut.Watch(ctx)
+	require.Nil(t, err)
+
+	e1 := demoEntity{Value1: "first"}
+	e2 := demoEntity{Value1: "second"}
+	require.Nil(t, cut.Set(ctx, "key1", e1, 0))
+	require.Nil(t, cut.Set(ctx, "key1", e2, 0))
+	require.Nil(t, cut.Remove(ctx, "key1"))
+	require.Nil(t, cut.Remove(ctx, "key1"))
+
+	require.Equal(t, Event[demoEntity]{Type: EventTypeAdded, Key: "key1", Value: &e1}, <-events)
+	require.Equal(t, Event[demoEntity]{Type: EventTypeUpdated, Key: "key1", Value: &e2}, <-events)
+	require.Equal(t, Event[demoEntity]{Type: EventTypeRemoved, Key: "key1"}, <-events)
+
+	cancel()
+	_, ok := <-events
+	require.False(t, ok)
+}
+
+func TestMemoryCacheWatchSlowConsumer(t *testing.T) {
+	ctx := context.TODO()
+	cut := NewMemoryCacheWithConfig[string](ctx, &MemoryCacheConfig{
+		WatchBufferSize: 2,
+	}).(WatchableCache[string])
+
+	events, err := cut.Watch(ctx)
+	require.Nil(t, err)
+
+	for _, key := range []string{"key1", "key2", "key3"} {
+		require.Nil(t, cut.Set(ctx, key, key, 0))
+	}
+
+	received := 0
+	for range events {
+		received++
+	}
+	require.Equal(t, 2, received)
+	// the dropped watcher no longer makes writes build events
+	require.False(t, cut.(*memoryCache[string]).hub.hasWatchers())
+}
+
+func TestMemoryCacheRetention(t *testing.T) {
+	ctx := context.TODO()
+	cut := NewMemoryCache[demoEntity]()
+
+	err := cut.Set(ctx, "short", demoEntity{Value1: "short"}, 20*time.Millisecond)
+	require.Nil(t, err)
+	err = cut.Set(ctx, "long", demoEntity{Value1: "long"}, time.Hour)
+	require.Nil(t, err)
+	err = cut.Set(ctx, "forever", demoEntity{Value1: "forever"}, 0)
+	require.Nil(t, err)
+
+	retention, err := cut.RemainingRetention(ctx, "long")
+	require.Nil(t, err)
+	require.Greater(t, retention, 59*time.Minute)
+	require.LessOrEqual(t, retention, time.Hour)
+
+	retention, err = cut.RemainingRetention(ctx, "forever")
+	require.Nil(t, err)
+	require.Equal(t, time.Duration(math.MaxInt64), retention)
+
+	time.Sleep(30 * time.Millisecond)
+
+	got, err := cut.Get(ctx, "short")
+	require.Nil(t, err)
+	require.Nil(t, got)
+
+	retention, err = cut.RemainingRetention(ctx, "short")
+	require.Nil(t, err)
+	require.Zero(t, retention)
+
+	keys, err := cut.Keys(ctx)
+	require.Nil(t, err)
+	require.ElementsMatch(t, []string{"long", "forever"}, keys)
+
+	entries, err := cut.Entries(ctx)
+	require.Nil(t, err)
+	require.Len(t, entries, 2)
+
+	values, err := cut.Values(ctx)
+	require.Nil(t, err)
+	require.Len(t, values, 2)
+}
+
+func TestMemoryCacheTouch(t *testing.T) {
+	ctx := context.TODO()
+	cut := NewMemoryCache[demoEntity]()
+
+	err := cut.Set(ctx, "key1", demoEntity{Value1: "value1"}, time.Minute)
+	require.Nil(t, err)
+
+	touched, err := cut.Touch(ctx, "key1", time.Hour)
+	require.Nil(t, err)
+	require.True(t, touched)
+	retention, err := cut.RemainingRetention(ctx, "key1")
+	require.Nil(t, err)
+	require.Greater(t, retention, 59*time.Minute)
+
+	touched, err = cut.Touch(ctx, "key1", 0)
+	require.Nil(t, err)
+	require.True(t, touched)
+	retention, err = cut.RemainingRetention(ctx, "key1")
+	require.Nil(t, err)
+	require.Equal(t, time.Duration(math.MaxInt64), retention)
+
+	got, err := cut.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.Equal(t, "value1", got.Value1)
+
+	touched, err = cut.Touch(ctx, "missing", time.Hour)
+	require.Nil(t, err)
+	require.False(t, touched)
+	got, err = cut.Get(ctx, "missing")
+	require.Nil(t, err)
+	require.Nil(t, got)
+}
+
+func TestMemoryCacheSlidingRetention(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	cut := NewMemoryCacheWithConfig[demoEntity](ctx, &MemoryCacheConfig{
+		SlidingRetention: time.Hour,
+	})
+
+	err := cut.SetMany(ctx, map[string]demoEntity{
+		"key1": {Value1: "value1"},
+		"key2": {Value1: "value2"},
+		"key3": {Value1: "value3"},
+	}, time.Minute)
+	require.Nil(t, err)
+
+	_, err = cut.Get(ctx, "key1")
+	require.Nil(t, err)
+	_, err = cut.GetMany(ctx, []string{"key2", "missing"})
+	require.Nil(t, err)
+
+	for _, key := range []string{"key1", "key2"} {
+		retention, err := cut.RemainingRetention(ctx, key)
+		require.Nil(t, err)
+		require.Greater(t, retention, 59*time.Minute)
+	}
+	// entries that are not read keep their retention
+	retention, err := cut.RemainingRetention(ctx, "key3")
+	require.Nil(t, err)
+	require.LessOrEqual(t, retention, time.Minute)
+}
+
+func TestMemoryCacheMetadata(t *testing.T) {
+	ctx := context.TODO()
+	cut := NewMemoryCache[demoEntity]().(MetadataCache[demoEntity])
+
+	value, metadata, err := cut.GetWithMetadata(ctx, "key1")
+	require.Nil(t, err)
+	require.Nil(t, value)
+	require.Nil(t, metadata)
+
+	require.Nil(t, cut.Set(ctx, "key1", demoEntity{Value1: "value1"}, time.Hour))
+	value, created, err := cut.GetWithMetadata(ctx, "key1")
+	require.Nil(t, err)
+	require.Equal(t, "value1", value.Value1)
+	require.Equal(t, int64(1), created.Version)
+	require.Equal(t, created.CreatedAt, created.UpdatedAt)
+	require.Greater(t, created.RemainingRetention, 59*time.Minute)
+
+	swapped, err := cut.CompareAndSwap(ctx, "key1", demoEntity{Value1: "value1"}, demoEntity{Value1: "value2"}, 0)
+	require.Nil(t, err)
+	require.True(t, swapped)
+	_, err = cut.Touch(ctx, "key1", 0)
+	require.Nil(t, err)
+	value, updated, err := cut.GetWithMetadata(ctx, "key1")
+	require.Nil(t, err)
+	require.Equal(t, "value2", value.Value1)
+	require.Equal(t, int64(2), updated.Version)
+	require.Equal(t, created.CreatedAt, updated.CreatedAt)
+	require.False(t, updated.UpdatedAt.Before(created.UpdatedAt))
+	require.Equal(t, time.Duration(math.MaxInt64), updated.RemainingRetention)
+
+	// a removed entry starts over
+	require.Nil(t, cut.Remove(ctx, "key1"))
+	require.Nil(t, cut.Set(ctx, "key1", demoEntity{Value1: "value3"}, 0))
+	_, recreated, err := cut.GetWithMetadata(ctx, "key1")
+	require.Nil(t, err)
+	require.Equal(t, int64(1), recreated.Version)
+}
+
+func TestMemoryCacheJanitor(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	cut := NewMemoryCacheWithConfig[demoEntity](ctx, &MemoryCacheConfig{
+		JanitorInterval: 10 * time.Millisecond,
+	})
+
+	err := cut.Set(ctx, "short", demoEntity{Value1: "short"}, 5*time.Millisecond)
+	require.Nil(t, err)
+
+	require.Eventually(t, func() bool {
+		mc := cut.(*memoryCache[demoEntity])
+		mc.mu.Lock()
+		defer mc.mu.Unlock()
+		_, ok := mc.entries["short"]
+		return !ok
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestBoundedMemoryCache(t *testing.T) {
+	testCases := []struct {
+		name     string
+		policy   func() EvictionPolicy
+		expected []string
+	}{
+		{name: "lru", policy: NewLRUEvictionPolicy, expected: []string{"key1", "key3"}},
+		{name: "fifo", policy: NewFIFOEvictionPolicy, expected: []string{"key2", "key3"}},
+		{name: "lfu", policy: NewLFUEvictionPolicy, expected: []string{"key1", "key3"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.TODO()
+			evicted := make([]string, 0)
+			cut := NewMemoryCacheWithConfig[demoEntity](ctx, &MemoryCacheConfig{
+				MaxEntries:     2,
+				EvictionPolicy: tc.policy,
+				OnEviction: func(key string, reason EvictionReason) {
+					require.Equal(t, EvictionReasonCapacity, reason)
+					evicted = append(evicted, key)
+				},
+			})
+
+			require.Nil(t, cut.Set(ctx, "key1", demoEntity{Value1: "1"}, 0))
+			require.Nil(t, cut.Set(ctx, "key2", demoEntity{Value1: "2"}, 0))
+			_, err := cut.Get(ctx, "key1")
+			require.Nil(t, err)
+			require.Nil(t, cut.Set(ctx, "key3", demoEntity{Value1: "3"}, 0))
+
+			keys, err := cut.Keys(ctx)
+			require.Nil(t, err)
+			require.ElementsMatch(t, tc.expected, keys)
+			require.Len(t, evicted, 1)
+			require.NotContains(t, tc.expected, evicted[0])
+		})
+	}
+}
+
+func TestMemoryCacheLFUKeepsInsertedEntry(t *testing.T) {
+	ctx := context.TODO()
+	cut := NewMemoryCacheWithConfig[demoEntity](ctx, &MemoryCacheConfig{
+		MaxEntries:     2,
+		EvictionPolicy: NewLFUEvictionPolicy,
+	})
+
+	require.Nil(t, cut.Set(ctx, "key1", demoEntity{Value1: "1"}, 0))
+	require.Nil(t, cut.Set(ctx, "key2", demoEntity{Value1: "2"}, 0))
+	for _, key := range []string{"key1", "key2", "key2"} {
+		_, err := cut.Get(ctx, key)
+		require.Nil(t, err)
+	}
+	// the new entry has the lowest frequency, the least frequently used of the others is evicted instead
+	require.Nil(t, cut.Set(ctx, "key3", demoEntity{Value1: "3"}, 0))
+	keys, err := cut.Keys(ctx)
+	require.Nil(t, err)
+	require.ElementsMatch(t, []string{"key2", "key3"}, keys)
+}
+
+func TestMemoryCacheMaxBytes(t *testing.T) {
+	ctx := context.TODO()
+	cut := NewMemoryCacheWithConfig[string](ctx, &MemoryCacheConfig{
+		MaxBytes: 20,
+	})
+
+	require.Nil(t, cut.Set(ctx, "a", "12345678", 0))
+	require.Nil(t, cut.Set(ctx, "b", "12345678", 0))
+
+	keys, err := cut.Keys(ctx)
+	require.Nil(t, err)
+	require.ElementsMatch(t, []string{"b"}, keys)
+
+	err = cut.Set(ctx, "c", "this value is way too large", 0)
+	require.ErrorAs(t, err, &ErrEntryTooLarge{})
+}
+
+func TestMemoryCacheClear(t *testing.T) {
+	ctx := context.TODO()
+	cut := NewMemoryCache[demoEntity]()
+
+	err := cut.SetMany(ctx, map[string]demoEntity{"key1": {Value1: "first"}, "key2": {Value1: "second"}}, 0)
+	require.Nil(t, err)
+
+	require.Nil(t, cut.Clear(ctx))
+	keys, err := cut.Keys(ctx)
+	require.Nil(t, err)
+	require.Empty(t, keys)
+
+	require.Nil(t, cut.Set(ctx, "key1", demoEntity{Value1: "third"}, 0))
+	got, err := cut.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.Equal(t, "third", got.Value1)
+}
+
 func p[E any](v E) *E {
 	return &v
 }
@@ -0,0 +1,31 @@
+package cache
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// EntryMetadata describes the history of an entry.
+type EntryMetadata struct {
+	CreatedAt time.Time
+	// UpdatedAt is the time of the last write, touching an entry or reading it in sliding mode does not count
+	UpdatedAt time.Time
+	// Version is 1 for new entries and incremented by every write. It restarts once an entry was removed
+	// or has expired, CreatedAt tells such generations apart.
+	Version int64
+	// RemainingRetention is math.MaxInt64 for entries without expiry
+	RemainingRetention time.Duration
+}
+
+// MetadataCache is implemented by caches that maintain the metadata of their entries on every write.
+// The caches returned by NewMemoryCache, NewTypedMemoryCache and NewRedisCache can be asserted to it.
+type MetadataCache[Entity any] interface {
+	Cache[Entity]
+
+	// GetWithMetadata returns the value of key together with its metadata, both are nil if key is not present.
+	GetWithMetadata(
+		ctx context.Context,
+		key string,
+	) (*Entity, *EntryMetadata, error)
+}
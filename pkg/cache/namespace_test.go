@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func TestNamespaces(t *testing.T) {
+	ctx := context.TODO()
+	disk, err := NewDiskCache[string](t.TempDir())
+	require.Nil(t, err)
+
+	testCases := []struct {
+		name  string
+		cache Cache[string]
+	}{
+		{name: "memory", cache: NewMemoryCache[string]()},
+		{name: "disk", cache: disk},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := tc.cache
+			tenantA := root.WithNamespace("tenant a")
+			tenantB := root.WithNamespace("tenant-b")
+			nested := tenantA.WithNamespace("nested")
+
+			require.Nil(t, root.Set(ctx, "key", "root", 0))
+			require.Nil(t, tenantA.Set(ctx, "key", "a", 0))
+			require.Nil(t, tenantB.Set(ctx, "key", "b", 0))
+			require.Nil(t, nested.Set(ctx, "key", "nested", 0))
+
+			entries, err := tenantA.Entries(ctx)
+			require.Nil(t, err)
+			require.Equal(t, map[string]string{"key": "a"}, entries)
+			got, err := tc.cache.WithNamespace("tenant a").WithNamespace("nested").Get(ctx, "key")
+			require.Nil(t, err)
+			require.Equal(t, "nested", *got)
+
+			namespaces, err := root.(NamespaceLister).Namespaces(ctx)
+			require.Nil(t, err)
+			require.ElementsMatch(t, []string{"tenant a", "tenant-b"}, namespaces)
+
+			// clearing a namespace includes its nested namespaces only
+			require.Nil(t, tenantA.Clear(ctx))
+			for _, c := range []Cache[string]{tenantA, nested} {
+				keys, err := c.Keys(ctx)
+				require.Nil(t, err)
+				require.Empty(t, keys)
+			}
+			for _, c := range []Cache[string]{root, tenantB} {
+				keys, err := c.Keys(ctx)
+				require.Nil(t, err)
+				require.Equal(t, []string{"key"}, keys)
+			}
+		})
+	}
+}
+
+func TestTieredCacheNamespaceInvalidation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	remote := NewMemoryCache[string]()
+	invalidator := NewMemoryInvalidator()
+	localA := NewMemoryCache[string]()
+	localB := NewMemoryCache[string]()
+	cutA := NewTieredCache(ctx, localA, remote, invalidator, nil).WithNamespace("tenant")
+	cutB := NewTieredCache(ctx, localB, remote, invalidator, nil).WithNamespace("tenant")
+	waitForSubscribers(t, invalidator, 2)
+
+	require.Nil(t, cutA.Set(ctx, "key1", "first", time.Hour))
+	_, err := cutB.Get(ctx, "key1")
+	require.Nil(t, err)
+	got, err := localB.WithNamespace("tenant").Get(ctx, "key1")
+	require.Nil(t, err)
+	require.Equal(t, "first", *got)
+
+	require.Nil(t, cutA.Set(ctx, "key1", "second", time.Hour))
+	got, err = localB.WithNamespace("tenant").Get(ctx, "key1")
+	require.Nil(t, err)
+	require.Nil(t, got)
+
+	_, err = cutB.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.Nil(t, cutA.Clear(ctx))
+	keys, err := localB.WithNamespace("tenant").Keys(ctx)
+	require.Nil(t, err)
+	require.Empty(t, keys)
+}
@@ -2,8 +2,11 @@ package cache
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"iter"
+	"math"
+	"sort"
 	"strings"
 	"time"
 
@@ -14,6 +17,47 @@ import (
 type redisCache[Entity any] struct {
 	client rueidis.Client
 	key    string
+	codec  Codec
+	config RedisCacheConfig
+}
+
+type RedisCacheConfig struct {
+	// Codec encodes the stored values, defaults to NewJSONCodec
+	Codec Codec
+	// ScanCount is the COUNT hint passed to every SCAN call when iterating the cache
+	ScanCount int64
+	// PublishEvents makes every write publish a change event, which is required for Watch.
+	// Entries expiring in Redis are not reported.
+	PublishEvents bool
+	// WatchBufferSize is the number of events buffered per watcher before it is dropped
+	WatchBufferSize int
+	// KeyEncoding defines how entry names are embedded into keys, defaults to KeyEncodingRaw, the layout
+	// of earlier versions. When switching an existing cache to KeyEncodingEscaped, its entries whose names
+	// contain any of '%|*?[]\/#' are not found by Get, Keys and the other reads until MigrateRedisCacheKeys
+	// has been run.
+	KeyEncoding KeyEncoding
+	// HashNamesLongerThan makes escaped names longer than the given number of bytes to be stored under
+	// their SHA-256 hash, a non-positive value disables hashing. It requires KeyEncodingEscaped. The original names are kept in the hash
+	// '<key>#names' until the entries are removed. Names of expired entries remain there until Clear.
+	HashNamesLongerThan int
+	// SlidingRetention, if positive, resets the retention of every entry read by Get or GetMany to the
+	// given duration using GETEX, which requires Redis 6.2 or later
+	SlidingRetention time.Duration
+	// TrackMetadata makes every write maintain the metadata returned by GetWithMetadata in a header
+	// preceding the stored value. Unlike the memory cache, Redis caches only maintain metadata if enabled,
+	// as values written with it cannot be read by caches without it, including those of earlier versions,
+	// and every write becomes a script call. GetWithMetadata fails with ErrUnsupportedOperation otherwise.
+	TrackMetadata bool
+}
+
+func CreateDefaultRedisCacheConfig() RedisCacheConfig {
+	return RedisCacheConfig{
+		Codec:           NewJSONCodec(),
+		ScanCount:       100,
+		PublishEvents:   false,
+		WatchBufferSize: 100,
+		KeyEncoding:     KeyEncodingRaw,
+	}
 }
 
 func NewRedisCache[Entity any](
@@ -21,37 +65,76 @@ func NewRedisCache[Entity any](
 	redisPassword string,
 	key string,
 ) (Cache[Entity], error) {
-	client, err := rueidis.NewClient(rueidis.ClientOption{
+	return NewRedisCacheWithConfig[Entity](redisURL, redisPassword, key, nil)
+}
+
+func NewRedisCacheWithConfig[Entity any](
+	redisURL string,
+	redisPassword string,
+	key string,
+	config *RedisCacheConfig,
+) (Cache[Entity], error) {
+	return NewRedisCacheWithOptions[Entity](rueidis.ClientOption{
 		InitAddress: []string{redisURL},
 		Password:    redisPassword,
-	})
+	}, key, config)
+}
+
+// NewRedisCacheWithOptions creates a cache with its own connection pool built from clientOption,
+// allowing TLS, ACL users, database selection, Sentinel or Cluster setups.
+func NewRedisCacheWithOptions[Entity any](
+	clientOption rueidis.ClientOption,
+	key string,
+	config *RedisCacheConfig,
+) (Cache[Entity], error) {
+	client, err := rueidis.NewClient(clientOption)
 	if err != nil {
 		return nil, err
 	}
+	return NewRedisCacheFromClient[Entity](client, key, config), nil
+}
 
+// NewRedisCacheFromClient creates a cache on top of an existing client so that one connection pool
+// can be shared by several caches, lockers and counters. The caller remains responsible for closing the client.
+func NewRedisCacheFromClient[Entity any](
+	client rueidis.Client,
+	key string,
+	config *RedisCacheConfig,
+) Cache[Entity] {
+	var vConfig RedisCacheConfig
+	if config != nil {
+		vConfig = *config
+	} else {
+		vConfig = CreateDefaultRedisCacheConfig()
+	}
+
+	if vConfig.Codec == nil {
+		vConfig.Codec = NewJSONCodec()
+	}
+	if vConfig.ScanCount <= 0 {
+		vConfig.ScanCount = CreateDefaultRedisCacheConfig().ScanCount
+	}
+	if vConfig.WatchBufferSize <= 0 {
+		vConfig.WatchBufferSize = CreateDefaultRedisCacheConfig().WatchBufferSize
+	}
 	return &redisCache[Entity]{
 		client: client,
 		key:    key,
-	}, nil
+		codec:  vConfig.Codec,
+		config: vConfig,
+	}
 }
 
 func (c *redisCache[Entity]) Entries(
 	ctx context.Context,
 ) (map[string]Entity, error) {
 	aulogging.Logger.Ctx(ctx).Debug().Printf("fetching all entries from cache '%s'", c.key)
-	keys, err := c.Keys(ctx)
-	if err != nil {
-		return nil, err
-	}
 	entries := make(map[string]Entity)
-	for _, key := range keys {
-		value, innerErr := c.Get(ctx, key)
-		if innerErr != nil {
-			return nil, innerErr
-		}
-		if value != nil {
-			entries[key] = *value
+	for entry, err := range c.All(ctx) {
+		if err != nil {
+			return nil, err
 		}
+		entries[entry.Key] = entry.Value
 	}
 	return entries, nil
 }
@@ -60,23 +143,22 @@ func (c *redisCache[Entity]) Keys(
 	ctx context.Context,
 ) ([]string, error) {
 	aulogging.Logger.Ctx(ctx).Debug().Printf("fetching all keys from cache '%s'", c.key)
-	result := c.client.Do(ctx, c.client.B().Keys().Pattern(c.entryKeyPattern()).Build())
-	if err := result.Error(); err != nil {
-		if rueidis.IsRedisNil(err) {
-			return nil, nil
-		} else if err != nil {
+	seen := make(map[string]bool)
+	keys := make([]string, 0)
+	for batch, err := range c.scan(ctx, c.entryKeyPattern()) {
+		if err != nil {
 			return nil, err
 		}
-	}
-
-	keysWithPrefix, err := result.AsStrSlice()
-	if err != nil {
-		return nil, err
-	}
-
-	keys := make([]string, 0)
-	for _, keyWithPrefix := range keysWithPrefix {
-		keys = append(keys, strings.TrimPrefix(keyWithPrefix, c.entryKeyPrefix()))
+		names, err := c.entryNames(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range names {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
 	}
 	return keys, nil
 }
@@ -96,6 +178,51 @@ func (c *redisCache[Entity]) Values(
 	return values, nil
 }
 
+func (c *redisCache[Entity]) All(
+	ctx context.Context,
+) iter.Seq2[Entry[Entity], error] {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("iterating all entries of cache '%s'", c.key)
+	return func(yield func(Entry[Entity], error) bool) {
+		for batch, err := range c.scan(ctx, c.entryKeyPattern()) {
+			if err != nil {
+				yield(Entry[Entity]{}, err)
+				return
+			}
+			messages, err := rueidis.MGet(c.client, ctx, batch)
+			if err != nil {
+				yield(Entry[Entity]{}, err)
+				return
+			}
+			names, err := c.entryNames(ctx, batch)
+			if err != nil {
+				yield(Entry[Entity]{}, err)
+				return
+			}
+			for _, keyWithPrefix := range batch {
+				key, known := names[keyWithPrefix]
+				message, ok := messages[keyWithPrefix]
+				if !known || !ok || message.IsNil() {
+					// entry expired or was removed since it was scanned
+					continue
+				}
+				data, innerErr := message.AsBytes()
+				if innerErr != nil {
+					yield(Entry[Entity]{}, innerErr)
+					return
+				}
+				value, _, innerErr := c.decodeData(key, data)
+				if innerErr != nil {
+					yield(Entry[Entity]{}, innerErr)
+					return
+				}
+				if !yield(Entry[Entity]{Key: key, Value: *value}, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
 func (c *redisCache[Entity]) Set(
 	ctx context.Context,
 	key string,
@@ -103,17 +230,22 @@ func (c *redisCache[Entity]) Set(
 	retention time.Duration,
 ) error {
 	aulogging.Logger.Ctx(ctx).Debug().Printf("setting value of '%s' in cache '%s'", key, c.key)
-	jsonBytes, err := json.Marshal(value)
+	data, err := marshalEntry(c.codec, c.codecEntry(key), value)
 	if err != nil {
 		return err
 	}
-
-	cmd := c.client.B().Set().Key(c.entryKey(key)).Value(string(jsonBytes))
-	if retention > 0 {
-		cmd.Ex(retention)
+	if err = c.recordNames(ctx, key); err != nil {
+		return err
 	}
-
-	return c.client.Do(ctx, cmd.Build()).Error()
+	errs := make(map[string]error)
+	results := c.writeValues(ctx, []string{key}, [][]byte{data}, retention, errs)
+	if err = errs[key]; err != nil {
+		return err
+	}
+	if c.config.PublishEvents {
+		return c.publishEvents(ctx, setEvent(key, data, results[0]))
+	}
+	return nil
 }
 
 func (c *redisCache[Entity]) Get(
@@ -121,33 +253,292 @@ func (c *redisCache[Entity]) Get(
 	key string,
 ) (*Entity, error) {
 	aulogging.Logger.Ctx(ctx).Debug().Printf("fetching value of '%s' from cache '%s'", key, c.key)
-	result := c.client.Do(ctx, c.client.B().Get().Key(c.entryKey(key)).Build())
+	return c.decodeResult(key, c.client.Do(ctx, c.getCommand(key)))
+}
+
+func (c *redisCache[Entity]) Remove(
+	ctx context.Context,
+	key string,
+) error {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("removing value of '%s' from cache '%s'", key, c.key)
+	result := c.client.Do(ctx, c.client.B().Del().Key(c.entryKey(key)).Build())
 	if err := result.Error(); err != nil {
-		if rueidis.IsRedisNil(err) {
-			return nil, nil
-		} else if err != nil {
-			return nil, err
-		}
+		return err
+	}
+	if err := c.forgetNames(ctx, key); err != nil {
+		return err
+	}
+	if c.config.PublishEvents {
+		return c.publishEvents(ctx, removeEvent(key, result)...)
 	}
+	return nil
+}
 
-	jsonString, err := result.ToString()
+func (c *redisCache[Entity]) GetMany(
+	ctx context.Context,
+	keys []string,
+) (map[string]Entity, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("fetching values of %d keys from cache '%s'", len(keys), c.key)
+	if c.config.SlidingRetention > 0 {
+		return c.getManySliding(ctx, keys)
+	}
+	entryKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		entryKeys = append(entryKeys, c.entryKey(key))
+	}
+	messages, err := rueidis.MGet(c.client, ctx, entryKeys)
 	if err != nil {
 		return nil, err
 	}
 
-	var value Entity
-	if err := json.Unmarshal([]byte(jsonString), &value); err != nil {
-		return nil, err
+	values := make(map[string]Entity)
+	errs := make(map[string]error)
+	for _, key := range keys {
+		message, ok := messages[c.entryKey(key)]
+		if !ok || message.IsNil() {
+			continue
+		}
+		data, innerErr := message.AsBytes()
+		if innerErr != nil {
+			errs[key] = innerErr
+			continue
+		}
+		value, _, innerErr := c.decodeData(key, data)
+		if innerErr != nil {
+			errs[key] = innerErr
+			continue
+		}
+		values[key] = *value
 	}
-	return &value, nil
+	return values, batchError(errs)
 }
 
-func (c *redisCache[Entity]) Remove(
+// getManySliding pipelines one GETEX per key, as there is no multi-key variant extending the retention
+func (c *redisCache[Entity]) getManySliding(
 	ctx context.Context,
-	key string,
+	keys []string,
+) (map[string]Entity, error) {
+	errs := make(map[string]error)
+	cmds := make(rueidis.Commands, 0, len(keys))
+	for _, key := range keys {
+		cmds = append(cmds, c.getCommand(key))
+	}
+	values := make(map[string]Entity)
+	for i, result := range c.doMulti(ctx, keys, cmds, errs) {
+		if _, failed := errs[keys[i]]; failed {
+			continue
+		}
+		value, err := c.decodeResult(keys[i], result)
+		if err != nil {
+			errs[keys[i]] = err
+		} else if value != nil {
+			values[keys[i]] = *value
+		}
+	}
+	return values, batchError(errs)
+}
+
+func (c *redisCache[Entity]) SetMany(
+	ctx context.Context,
+	entries map[string]Entity,
+	retention time.Duration,
 ) error {
-	aulogging.Logger.Ctx(ctx).Debug().Printf("removing value of '%s' from cache '%s'", key, c.key)
-	return c.client.Do(ctx, c.client.B().Del().Key(c.entryKey(key)).Build()).Error()
+	aulogging.Logger.Ctx(ctx).Debug().Printf("setting values of %d keys in cache '%s'", len(entries), c.key)
+	errs := make(map[string]error)
+	keys := make([]string, 0, len(entries))
+	values := make([][]byte, 0, len(entries))
+	for key, value := range entries {
+		data, err := marshalEntry(c.codec, c.codecEntry(key), value)
+		if err != nil {
+			errs[key] = err
+			continue
+		}
+		keys = append(keys, key)
+		values = append(values, data)
+	}
+	if err := c.recordNames(ctx, keys...); err != nil {
+		return err
+	}
+	results := c.writeValues(ctx, keys, values, retention, errs)
+	if c.config.PublishEvents {
+		events := make([]redisEvent, 0, len(results))
+		for i, result := range results {
+			if _, failed := errs[keys[i]]; !failed {
+				events = append(events, setEvent(keys[i], values[i], result))
+			}
+		}
+		if err := c.publishEvents(ctx, events...); err != nil {
+			return err
+		}
+	}
+	return batchError(errs)
+}
+
+func (c *redisCache[Entity]) RemoveMany(
+	ctx context.Context,
+	keys []string,
+) error {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("removing values of %d keys from cache '%s'", len(keys), c.key)
+	errs := make(map[string]error)
+	cmds := make(rueidis.Commands, 0, len(keys))
+	for _, key := range keys {
+		cmds = append(cmds, c.client.B().Del().Key(c.entryKey(key)).Build())
+	}
+	results := c.doMulti(ctx, keys, cmds, errs)
+	removed := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, failed := errs[key]; !failed {
+			removed = append(removed, key)
+		}
+	}
+	if err := c.forgetNames(ctx, removed...); err != nil {
+		return err
+	}
+	if c.config.PublishEvents {
+		events := make([]redisEvent, 0, len(results))
+		for i, result := range results {
+			if _, failed := errs[keys[i]]; !failed {
+				events = append(events, removeEvent(keys[i], result)...)
+			}
+		}
+		if err := c.publishEvents(ctx, events...); err != nil {
+			return err
+		}
+	}
+	return batchError(errs)
+}
+
+// Clear unlinks the entries batch by batch while scanning, so it neither blocks Redis on large caches
+// nor needs to hold all keys in memory, followed by the entries of all namespaces. Entries written
+// concurrently might survive.
+func (c *redisCache[Entity]) Clear(
+	ctx context.Context,
+) error {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("clearing cache '%s'", c.key)
+	// the namespaces are collected before anything is deleted
+	namespaces, err := c.Namespaces(ctx)
+	if err != nil {
+		return err
+	}
+	for batch, err := range c.scan(ctx, c.entryKeyPattern()) {
+		if err != nil {
+			return err
+		}
+		names, err := c.entryNames(ctx, batch)
+		if err != nil {
+			return err
+		}
+		// one command per key keeps the batch valid in cluster mode, where keys span several slots
+		cmds := make(rueidis.Commands, 0, len(batch))
+		for _, keyWithPrefix := range batch {
+			cmds = append(cmds, c.client.B().Unlink().Key(keyWithPrefix).Build())
+		}
+		events := make([]redisEvent, 0)
+		for i, result := range c.client.DoMulti(ctx, cmds...) {
+			if err = result.Error(); err != nil {
+				return err
+			}
+			if name, known := names[batch[i]]; known && c.config.PublishEvents {
+				events = append(events, removeEvent(name, result)...)
+			}
+		}
+		if err = c.publishEvents(ctx, events...); err != nil {
+			return err
+		}
+	}
+	if err = c.client.Do(ctx, c.client.B().Unlink().Key(c.namesKey()).Build()).Error(); err != nil {
+		return err
+	}
+
+	for _, namespace := range namespaces {
+		if err = c.WithNamespace(namespace).Clear(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *redisCache[Entity]) SetIfAbsent(
+	ctx context.Context,
+	key string,
+	value Entity,
+	retention time.Duration,
+) (bool, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("setting value of '%s' in cache '%s' if absent", key, c.key)
+	data, err := marshalEntry(c.codec, c.codecEntry(key), value)
+	if err != nil {
+		return false, err
+	}
+	if err = c.recordNames(ctx, key); err != nil {
+		return false, err
+	}
+	cmd := c.client.B().Set().Key(c.entryKey(key)).Value(rueidis.BinaryString(c.withMetadata(data, nil))).Nx()
+	if retention > 0 {
+		cmd.PxMilliseconds(expiryMillis(retention))
+	}
+	if err = c.client.Do(ctx, cmd.Build()).Error(); err != nil {
+		if rueidis.IsRedisNil(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if c.config.PublishEvents {
+		return true, c.publishEvents(ctx, redisEvent{Type: EventTypeAdded, Key: key, Value: data})
+	}
+	return true, nil
+}
+
+func (c *redisCache[Entity]) CompareAndSwap(
+	ctx context.Context,
+	key string,
+	oldValue Entity,
+	newValue Entity,
+	retention time.Duration,
+) (bool, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("swapping value of '%s' in cache '%s'", key, c.key)
+	data, err := marshalEntry(c.codec, c.codecEntry(key), newValue)
+	if err != nil {
+		return false, err
+	}
+	swapped, err := c.watchedExec(ctx, key, func(current *Entity) bool {
+		return current != nil && valuesEqual(*current, oldValue)
+	}, func(builder rueidis.Builder, metadata *EntryMetadata) rueidis.Completed {
+		cmd := builder.Set().Key(c.entryKey(key)).Value(rueidis.BinaryString(c.withMetadata(data, metadata)))
+		if retention > 0 {
+			cmd.PxMilliseconds(expiryMillis(retention))
+		}
+		return cmd.Build()
+	})
+	if err != nil || !swapped {
+		return false, err
+	}
+	if c.config.PublishEvents {
+		return true, c.publishEvents(ctx, redisEvent{Type: EventTypeUpdated, Key: key, Value: data})
+	}
+	return true, nil
+}
+
+func (c *redisCache[Entity]) RemoveIfEquals(
+	ctx context.Context,
+	key string,
+	value Entity,
+) (bool, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("removing value of '%s' from cache '%s' if unchanged", key, c.key)
+	removed, err := c.watchedExec(ctx, key, func(current *Entity) bool {
+		return current != nil && valuesEqual(*current, value)
+	}, func(builder rueidis.Builder, _ *EntryMetadata) rueidis.Completed {
+		return builder.Del().Key(c.entryKey(key)).Build()
+	})
+	if err != nil || !removed {
+		return false, err
+	}
+	if err = c.forgetNames(ctx, key); err != nil {
+		return true, err
+	}
+	if c.config.PublishEvents {
+		return true, c.publishEvents(ctx, redisEvent{Type: EventTypeRemoved, Key: key})
+	}
+	return true, nil
 }
 
 func (c *redisCache[Entity]) RemainingRetention(
@@ -155,7 +546,7 @@ func (c *redisCache[Entity]) RemainingRetention(
 	key string,
 ) (time.Duration, error) {
 	aulogging.Logger.Ctx(ctx).Debug().Printf("fetching remaining retention of '%s' cache '%s'", key, c.key)
-	result := c.client.Do(ctx, c.client.B().Ttl().Key(c.entryKey(key)).Build())
+	result := c.client.Do(ctx, c.client.B().Pttl().Key(c.entryKey(key)).Build())
 	if err := result.Error(); err != nil {
 		return 0, err
 	}
@@ -164,7 +555,270 @@ func (c *redisCache[Entity]) RemainingRetention(
 	if err != nil {
 		return 0, err
 	}
-	return time.Millisecond * time.Duration(ttlInMillis), nil
+	return retentionFromPTTL(ttlInMillis), nil
+}
+
+// Touch uses PEXPIRE, or GETEX with PERSIST for non-positive retentions as PERSIST does not tell
+// missing keys apart from those without expiry.
+func (c *redisCache[Entity]) Touch(
+	ctx context.Context,
+	key string,
+	retention time.Duration,
+) (bool, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("touching value of '%s' in cache '%s'", key, c.key)
+	if retention <= 0 {
+		err := c.client.Do(ctx, c.client.B().Getex().Key(c.entryKey(key)).Persist().Build()).Error()
+		if rueidis.IsRedisNil(err) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	cmd := c.client.B().Pexpire().Key(c.entryKey(key)).Milliseconds(expiryMillis(retention)).Build()
+	return c.client.Do(ctx, cmd).AsBool()
+}
+
+// WithNamespace returns a view whose entries are stored under '<key>#ns:<namespace>|<name>', with the namespace
+// escaped like entry names.
+func (c *redisCache[Entity]) WithNamespace(
+	namespace string,
+) Cache[Entity] {
+	return &redisCache[Entity]{
+		client: c.client,
+		key:    namespaceKey(c.key, namespace),
+		codec:  c.codec,
+		config: c.config,
+	}
+}
+
+func (c *redisCache[Entity]) Namespaces(
+	ctx context.Context,
+) ([]string, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("fetching namespaces of cache '%s'", c.key)
+	return scanNamespaces(c.key, c.scan(ctx, namespacePattern(c.key)))
+}
+
+// retentionFromPTTL maps the special PTTL replies onto the semantics of the memory cache:
+// -2 (missing key) becomes zero, -1 (no expiry) becomes math.MaxInt64
+func retentionFromPTTL(ttlInMillis int64) time.Duration {
+	switch ttlInMillis {
+	case -2:
+		return 0
+	case -1:
+		return math.MaxInt64
+	default:
+		return time.Millisecond * time.Duration(ttlInMillis)
+	}
+}
+
+// expiryMillis converts a positive retention to milliseconds for PEXPIRE and HPEXPIRE, rounding up so
+// that retentions below one millisecond do not delete the entry
+func expiryMillis(retention time.Duration) int64 {
+	return max(1, (retention + time.Millisecond - 1).Milliseconds())
+}
+
+// scriptExpiryMillis is the retention argument of scripts, which treat zero as no expiry
+func scriptExpiryMillis(retention time.Duration) int64 {
+	if retention <= 0 {
+		return 0
+	}
+	return expiryMillis(retention)
+}
+
+// watchedExec runs the command created by build from the metadata of the current value in a transaction
+// if condition holds for the current value of key. The transaction is discarded if key is modified
+// concurrently, which is reported as not executed.
+func (c *redisCache[Entity]) watchedExec(
+	ctx context.Context,
+	key string,
+	condition func(current *Entity) bool,
+	build func(builder rueidis.Builder, metadata *EntryMetadata) rueidis.Completed,
+) (bool, error) {
+	executed := false
+	err := c.client.Dedicated(func(client rueidis.DedicatedClient) error {
+		entryKey := c.entryKey(key)
+		if err := client.Do(ctx, client.B().Watch().Key(entryKey).Build()).Error(); err != nil {
+			return err
+		}
+		current, metadata, err := c.decodeEntry(key, client.Do(ctx, client.B().Get().Key(entryKey).Build()))
+		if err != nil || !condition(current) {
+			if unwatchErr := client.Do(ctx, client.B().Unwatch().Build()).Error(); unwatchErr != nil {
+				return errors.Join(err, unwatchErr)
+			}
+			return err
+		}
+
+		results := client.DoMulti(ctx,
+			client.B().Multi().Build(),
+			build(client.B(), metadata),
+			client.B().Exec().Build(),
+		)
+		if err = results[len(results)-1].Error(); err != nil {
+			if rueidis.IsRedisNil(err) {
+				return nil
+			}
+			return err
+		}
+		executed = true
+		return nil
+	})
+	return executed, err
+}
+
+// decodeResult decodes the reply of a GET command, nil is returned for missing keys
+func (c *redisCache[Entity]) decodeResult(key string, result rueidis.RedisResult) (*Entity, error) {
+	value, _, err := c.decodeEntry(key, result)
+	return value, err
+}
+
+// decodeEntry decodes the reply of a GET command together with the metadata of the value
+func (c *redisCache[Entity]) decodeEntry(key string, result rueidis.RedisResult) (*Entity, *EntryMetadata, error) {
+	if err := result.Error(); err != nil {
+		if rueidis.IsRedisNil(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	data, err := result.AsBytes()
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.decodeData(key, data)
+}
+
+func (c *redisCache[Entity]) decodeData(key string, data []byte) (*Entity, *EntryMetadata, error) {
+	metadata, data, err := c.splitMetadata(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	value, err := decodeEntry[Entity](c.codec, c.codecEntry(key), data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return value, metadata, nil
+}
+
+// getCommand reads the entry of key, extending its retention in sliding mode
+func (c *redisCache[Entity]) getCommand(key string) rueidis.Completed {
+	if c.config.SlidingRetention > 0 {
+		return c.client.B().Getex().Key(c.entryKey(key)).PxMilliseconds(expiryMillis(c.config.SlidingRetention)).Build()
+	}
+	return c.client.B().Get().Key(c.entryKey(key)).Build()
+}
+
+func (c *redisCache[Entity]) setCommand(
+	key string,
+	data []byte,
+	retention time.Duration,
+) rueidis.Completed {
+	cmd := c.client.B().Set().Key(c.entryKey(key)).Value(rueidis.BinaryString(data))
+	if c.config.PublishEvents {
+		// the previous value distinguishes additions from updates
+		cmd.Get()
+	}
+	if retention > 0 {
+		cmd.PxMilliseconds(expiryMillis(retention))
+	}
+	return cmd.Build()
+}
+
+// doMulti pipelines one command per key and records the failure of each command for its key
+func (c *redisCache[Entity]) doMulti(
+	ctx context.Context,
+	keys []string,
+	cmds rueidis.Commands,
+	errs map[string]error,
+) []rueidis.RedisResult {
+	if len(cmds) == 0 {
+		return nil
+	}
+	results := c.client.DoMulti(ctx, cmds...)
+	recordErrors(keys, results, errs)
+	return results
+}
+
+// recordErrors records the failure of each result for its key, nil replies are not considered failures
+func recordErrors(keys []string, results []rueidis.RedisResult, errs map[string]error) {
+	for i, result := range results {
+		if err := result.Error(); err != nil && !rueidis.IsRedisNil(err) {
+			errs[keys[i]] = err
+		}
+	}
+}
+
+// scan iterates the keys matching pattern in batches using a SCAN cursor
+func (c *redisCache[Entity]) scan(
+	ctx context.Context,
+	pattern string,
+) iter.Seq2[[]string, error] {
+	return scanKeys(ctx, c.client, pattern, c.config.ScanCount)
+}
+
+func scanKeys(
+	ctx context.Context,
+	client rueidis.Client,
+	pattern string,
+	count int64,
+) iter.Seq2[[]string, error] {
+	return func(yield func([]string, error) bool) {
+		var cursor uint64
+		for {
+			cmd := client.B().Scan().Cursor(cursor).Match(pattern).Count(count).Build()
+			scanEntry, err := client.Do(ctx, cmd).AsScanEntry()
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if len(scanEntry.Elements) > 0 && !yield(scanEntry.Elements, nil) {
+				return
+			}
+			if scanEntry.Cursor == 0 {
+				return
+			}
+			cursor = scanEntry.Cursor
+		}
+	}
+}
+
+// namespaceSeparator joins the key of a cache and the escaped name of a namespace. Unlike '/', it does not
+// occur in the keys of caches created with a plain name, so those are not mistaken for namespaces.
+const namespaceSeparator = "#ns:"
+
+// namespaceKey derives the key of a namespace, which is escaped like entry names and therefore never hashed
+func namespaceKey(key string, namespace string) string {
+	return fmt.Sprintf("%s%s%s", key, namespaceSeparator, escapeName(namespace))
+}
+
+func namespacePattern(key string) string {
+	return fmt.Sprintf("%s*", escapeGlob(key+namespaceSeparator))
+}
+
+// scanNamespaces collects the distinct direct child namespaces of key from the scanned keys of its subtree
+func scanNamespaces(key string, batches iter.Seq2[[]string, error]) ([]string, error) {
+	seen := make(map[string]bool)
+	namespaces := make([]string, 0)
+	for batch, err := range batches {
+		if err != nil {
+			return nil, err
+		}
+		for _, keyInSubtree := range batch {
+			namespace := strings.TrimPrefix(keyInSubtree, key+namespaceSeparator)
+			// escaped namespaces are followed by nested namespaces, entry names or the names hash
+			if end := strings.IndexAny(namespace, "|#"); end >= 0 {
+				namespace = namespace[:end]
+			}
+			namespace, err = unescapeName(namespace)
+			if err != nil {
+				return nil, err
+			}
+			if !seen[namespace] {
+				seen[namespace] = true
+				namespaces = append(namespaces, namespace)
+			}
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
 }
 
 func (c *redisCache[Entity]) entryKeyPrefix() string {
@@ -172,9 +826,15 @@ func (c *redisCache[Entity]) entryKeyPrefix() string {
 }
 
 func (c *redisCache[Entity]) entryKeyPattern() string {
-	return fmt.Sprintf("%s*", c.entryKeyPrefix())
+	return fmt.Sprintf("%s*", escapeGlob(c.entryKeyPrefix()))
 }
 
 func (c *redisCache[Entity]) entryKey(key string) string {
+	return fmt.Sprintf("%s%s", c.entryKeyPrefix(), c.encodeName(key))
+}
+
+// codecEntry identifies the entry of key to the codec independent of the key encoding, so values
+// remain readable after MigrateRedisCacheKeys
+func (c *redisCache[Entity]) codecEntry(key string) string {
 	return fmt.Sprintf("%s%s", c.entryKeyPrefix(), key)
 }
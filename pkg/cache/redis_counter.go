@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/redis/rueidis"
+)
+
+type redisCounter struct {
+	client    rueidis.Client
+	key       string
+	retention time.Duration
+}
+
+// NewRedisCounter creates a counter whose values are reset after retention, a non-positive retention
+// keeps them forever. The key must not be shared with a cache, retention requires Redis 7 or later.
+func NewRedisCounter(
+	redisURL string,
+	redisPassword string,
+	key string,
+	retention time.Duration,
+) (Counter, error) {
+	client, err := rueidis.NewClient(rueidis.ClientOption{
+		InitAddress: []string{redisURL},
+		Password:    redisPassword,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewRedisCounterFromClient(client, key, retention), nil
+}
+
+// NewRedisCounterFromClient creates a counter on top of an existing, possibly shared client.
+func NewRedisCounterFromClient(
+	client rueidis.Client,
+	key string,
+	retention time.Duration,
+) Counter {
+	return &redisCounter{
+		client:    client,
+		key:       key,
+		retention: retention,
+	}
+}
+
+func (c *redisCounter) Increment(
+	ctx context.Context,
+	key string,
+	delta int64,
+) (int64, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("incrementing '%s' of counter '%s' by %d", key, c.key, delta)
+	cmds := rueidis.Commands{c.client.B().Incrby().Key(c.counterKey(key)).Increment(delta).Build()}
+	if c.retention > 0 {
+		// NX only sets the expiry when the counter was created, keeping its window fixed
+		cmds = append(cmds, c.client.B().Pexpire().Key(c.counterKey(key)).
+			Milliseconds(expiryMillis(c.retention)).Nx().Build())
+	}
+	results := c.client.DoMulti(ctx, cmds...)
+	for _, result := range results[1:] {
+		if err := result.Error(); err != nil {
+			return 0, err
+		}
+	}
+	return results[0].AsInt64()
+}
+
+func (c *redisCounter) Decrement(
+	ctx context.Context,
+	key string,
+	delta int64,
+) (int64, error) {
+	return c.Increment(ctx, key, -delta)
+}
+
+func (c *redisCounter) GetCount(
+	ctx context.Context,
+	key string,
+) (int64, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("fetching '%s' of counter '%s'", key, c.key)
+	count, err := c.client.Do(ctx, c.client.B().Get().Key(c.counterKey(key)).Build()).AsInt64()
+	if rueidis.IsRedisNil(err) {
+		return 0, nil
+	}
+	return count, err
+}
+
+func (c *redisCounter) Reset(
+	ctx context.Context,
+	key string,
+) error {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("resetting '%s' of counter '%s'", key, c.key)
+	return c.client.Do(ctx, c.client.B().Del().Key(c.counterKey(key)).Build()).Error()
+}
+
+func (c *redisCounter) counterKey(key string) string {
+	return fmt.Sprintf("%s|%s", c.key, key)
+}
@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/redis/rueidis"
+)
+
+type redisEvent struct {
+	Type  EventType `json:"type"`
+	Key   string    `json:"key"`
+	Value []byte    `json:"value,omitempty"`
+}
+
+// Watch returns once Redis has confirmed the subscription, so events of all writes completed afterward are
+// delivered. A failing subscription is returned as error.
+func (c *redisCache[Entity]) Watch(
+	ctx context.Context,
+) (<-chan Event[Entity], error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("watching cache '%s'", c.key)
+	if !c.config.PublishEvents {
+		return nil, NewErrWatchDisabled(c.key)
+	}
+
+	w := newWatcher[Entity](c.config.WatchBufferSize)
+	wCtx, cancel := context.WithCancel(ctx)
+	subscribed := make(chan struct{})
+	var subscribedOnce sync.Once
+	// the hook is called again after reconnects
+	hookCtx := rueidis.WithOnSubscriptionHook(wCtx, func(subscription rueidis.PubSubSubscription) {
+		if subscription.Kind == "subscribe" {
+			subscribedOnce.Do(func() { close(subscribed) })
+		}
+	})
+	received := make(chan error, 1)
+	go func() {
+		defer cancel()
+		defer w.close()
+		subscribe := c.client.B().Subscribe().Channel(c.eventChannel()).Build()
+		err := c.client.Receive(hookCtx, subscribe, func(msg rueidis.PubSubMessage) {
+			event, err := c.decodeEvent(msg.Message)
+			if err != nil {
+				aulogging.Logger.Ctx(wCtx).Warn().WithErr(err).
+					Printf("failed to decode event of cache '%s', dropping watcher", c.key)
+				cancel()
+				return
+			}
+			if !w.send(event) {
+				cancel()
+			}
+		})
+		if err != nil && wCtx.Err() == nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("watching cache '%s' failed", c.key)
+		}
+		received <- err
+	}()
+
+	select {
+	case <-subscribed:
+		return w.events, nil
+	case err := <-received:
+		if err != nil {
+			return nil, err
+		}
+		return w.events, nil
+	case <-ctx.Done():
+		cancel()
+		return nil, ctx.Err()
+	}
+}
+
+func (c *redisCache[Entity]) publishEvents(
+	ctx context.Context,
+	events ...redisEvent,
+) error {
+	if len(events) == 0 {
+		return nil
+	}
+	cmds := make(rueidis.Commands, 0, len(events))
+	for _, event := range events {
+		message, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		cmds = append(cmds, c.client.B().Publish().Channel(c.eventChannel()).Message(string(message)).Build())
+	}
+	for _, result := range c.client.DoMulti(ctx, cmds...) {
+		if err := result.Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *redisCache[Entity]) decodeEvent(message string) (Event[Entity], error) {
+	var rEvent redisEvent
+	if err := json.Unmarshal([]byte(message), &rEvent); err != nil {
+		return Event[Entity]{}, err
+	}
+	event := Event[Entity]{Type: rEvent.Type, Key: rEvent.Key}
+	if rEvent.Type != EventTypeRemoved {
+		value, err := decodeEntry[Entity](c.codec, c.codecEntry(rEvent.Key), rEvent.Value)
+		if err != nil {
+			return Event[Entity]{}, err
+		}
+		event.Value = value
+	}
+	return event, nil
+}
+
+func (c *redisCache[Entity]) eventChannel() string {
+	return fmt.Sprintf("%s|events", c.key)
+}
+
+// setEvent derives the event of a SET ... GET command from the returned previous value
+func setEvent(key string, data []byte, result rueidis.RedisResult) redisEvent {
+	event := redisEvent{Type: EventTypeUpdated, Key: key, Value: data}
+	if rueidis.IsRedisNil(result.Error()) {
+		event.Type = EventTypeAdded
+	}
+	return event
+}
+
+// removeEvent derives the event of a DEL command, nothing is reported if the key did not exist
+func removeEvent(key string, result rueidis.RedisResult) []redisEvent {
+	if removed, err := result.AsInt64(); err != nil || removed == 0 {
+		return nil
+	}
+	return []redisEvent{{Type: EventTypeRemoved, Key: key}}
+}
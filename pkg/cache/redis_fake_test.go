@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/redis/rueidis"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis answers the commands of a rueidis client over in-memory connections, using reply to
+// create the raw RESP3 reply of every command besides the connection handshake
+type fakeRedis struct {
+	mu       sync.Mutex
+	commands [][]string
+	reply    func(command []string) string
+}
+
+func newFakeRedisClient(t *testing.T, reply func(command []string) string) (rueidis.Client, *fakeRedis) {
+	fake := &fakeRedis{reply: reply}
+	client, err := rueidis.NewClient(rueidis.ClientOption{
+		InitAddress:       []string{"fake:6379"},
+		ForceSingleClient: true,
+		DisableCache:      true,
+		DialCtxFn: func(context.Context, string, *net.Dialer, *tls.Config) (net.Conn, error) {
+			serverConn, clientConn := net.Pipe()
+			go fake.serve(serverConn)
+			return clientConn, nil
+		},
+	})
+	require.Nil(t, err)
+	t.Cleanup(client.Close)
+	return client, fake
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	for {
+		command, err := readFakeCommand(reader)
+		if err != nil {
+			return
+		}
+		var reply string
+		switch strings.ToUpper(command[0]) {
+		case "HELLO":
+			reply = "%2\r\n+proto\r\n:3\r\n+version\r\n+7.4.0\r\n"
+		case "CLIENT", "PING":
+			reply = "+OK\r\n"
+		default:
+			f.mu.Lock()
+			f.commands = append(f.commands, command)
+			f.mu.Unlock()
+			reply = f.reply(command)
+		}
+		if _, err = conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+// recorded returns the commands answered by reply so far
+func (f *fakeRedis) recorded() [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]string(nil), f.commands...)
+}
+
+func readFakeCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	command := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		if line, err = reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		length, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, length+2)
+		if _, err = io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		command = append(command, string(data[:length]))
+	}
+	return command, nil
+}
+
+func bulkReply(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
@@ -0,0 +1,607 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"math"
+	"strconv"
+	"time"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/redis/rueidis"
+)
+
+// setIfAbsentHashScript sets the field and its expiry atomically, ARGV: field, value, retention in milliseconds
+var setIfAbsentHashScript = rueidis.NewLuaScript(`
+if redis.call('HSETNX', KEYS[1], ARGV[1], ARGV[2]) == 0 then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call('HPEXPIRE', KEYS[1], ARGV[3], 'FIELDS', 1, ARGV[1])
+end
+return 1
+`)
+
+const (
+	// watchedExecAttempts bounds the transactions attempted by a single conditional write
+	watchedExecAttempts = 8
+	// watchedExecBackoff is the delay before the first retry of an aborted transaction, doubled for every further one
+	watchedExecBackoff = time.Millisecond
+)
+
+type redisHashCache[Entity any] struct {
+	client rueidis.Client
+	key    string
+	codec  Codec
+	config RedisHashCacheConfig
+}
+
+type RedisHashCacheConfig struct {
+	// Codec encodes the stored values, defaults to NewJSONCodec
+	Codec Codec
+	// ScanCount is the COUNT hint passed to every HSCAN call when iterating the cache
+	ScanCount int64
+	// FieldExpiry applies retentions to the single fields using HPEXPIRE, which requires Redis 7.4 or later.
+	// Without it, retentions are ignored and entries are kept until they are removed.
+	FieldExpiry bool
+	// SlidingRetention, if positive, resets the retention of every entry read by Get or GetMany to the
+	// given duration. It requires FieldExpiry and is ignored otherwise.
+	SlidingRetention time.Duration
+}
+
+func CreateDefaultRedisHashCacheConfig() RedisHashCacheConfig {
+	return RedisHashCacheConfig{
+		Codec:       NewJSONCodec(),
+		ScanCount:   100,
+		FieldExpiry: false,
+	}
+}
+
+// NewRedisHashCache creates a cache that stores all entries as fields of the single Redis hash key.
+func NewRedisHashCache[Entity any](
+	redisURL string,
+	redisPassword string,
+	key string,
+) (Cache[Entity], error) {
+	return NewRedisHashCacheWithConfig[Entity](redisURL, redisPassword, key, nil)
+}
+
+func NewRedisHashCacheWithConfig[Entity any](
+	redisURL string,
+	redisPassword string,
+	key string,
+	config *RedisHashCacheConfig,
+) (Cache[Entity], error) {
+	return NewRedisHashCacheWithOptions[Entity](rueidis.ClientOption{
+		InitAddress: []string{redisURL},
+		Password:    redisPassword,
+	}, key, config)
+}
+
+func NewRedisHashCacheWithOptions[Entity any](
+	clientOption rueidis.ClientOption,
+	key string,
+	config *RedisHashCacheConfig,
+) (Cache[Entity], error) {
+	client, err := rueidis.NewClient(clientOption)
+	if err != nil {
+		return nil, err
+	}
+	return NewRedisHashCacheFromClient[Entity](client, key, config), nil
+}
+
+func NewRedisHashCacheFromClient[Entity any](
+	client rueidis.Client,
+	key string,
+	config *RedisHashCacheConfig,
+) Cache[Entity] {
+	var vConfig RedisHashCacheConfig
+	if config != nil {
+		vConfig = *config
+	} else {
+		vConfig = CreateDefaultRedisHashCacheConfig()
+	}
+
+	if vConfig.Codec == nil {
+		vConfig.Codec = NewJSONCodec()
+	}
+	if vConfig.ScanCount <= 0 {
+		vConfig.ScanCount = CreateDefaultRedisHashCacheConfig().ScanCount
+	}
+	return &redisHashCache[Entity]{
+		client: client,
+		key:    key,
+		codec:  vConfig.Codec,
+		config: vConfig,
+	}
+}
+
+func (c *redisHashCache[Entity]) Entries(
+	ctx context.Context,
+) (map[string]Entity, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("fetching all entries from hash cache '%s'", c.key)
+	messages, err := c.client.Do(ctx, c.client.B().Hgetall().Key(c.key).Build()).AsMap()
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]Entity, len(messages))
+	for key, message := range messages {
+		value, innerErr := c.decodeMessage(key, message)
+		if innerErr != nil {
+			return nil, innerErr
+		}
+		entries[key] = *value
+	}
+	return entries, nil
+}
+
+func (c *redisHashCache[Entity]) Keys(
+	ctx context.Context,
+) ([]string, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("fetching all keys from hash cache '%s'", c.key)
+	return c.client.Do(ctx, c.client.B().Hkeys().Key(c.key).Build()).AsStrSlice()
+}
+
+func (c *redisHashCache[Entity]) Values(
+	ctx context.Context,
+) ([]Entity, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("fetching all values from hash cache '%s'", c.key)
+	// the fields are fetched as well, as values are decoded for the entry they are stored under
+	messages, err := c.client.Do(ctx, c.client.B().Hgetall().Key(c.key).Build()).AsMap()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]Entity, 0, len(messages))
+	for key, message := range messages {
+		value, innerErr := c.decodeMessage(key, message)
+		if innerErr != nil {
+			return nil, innerErr
+		}
+		values = append(values, *value)
+	}
+	return values, nil
+}
+
+func (c *redisHashCache[Entity]) All(
+	ctx context.Context,
+) iter.Seq2[Entry[Entity], error] {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("iterating all entries of hash cache '%s'", c.key)
+	return func(yield func(Entry[Entity], error) bool) {
+		var cursor uint64
+		for {
+			cmd := c.client.B().Hscan().Key(c.key).Cursor(cursor).Count(c.config.ScanCount).Build()
+			scanEntry, err := c.client.Do(ctx, cmd).AsScanEntry()
+			if err != nil {
+				yield(Entry[Entity]{}, err)
+				return
+			}
+			// HSCAN replies with alternating fields and values
+			for i := 0; i+1 < len(scanEntry.Elements); i += 2 {
+				value, innerErr := decodeEntry[Entity](c.codec, c.codecEntry(scanEntry.Elements[i]), []byte(scanEntry.Elements[i+1]))
+				if innerErr != nil {
+					yield(Entry[Entity]{}, innerErr)
+					return
+				}
+				if !yield(Entry[Entity]{Key: scanEntry.Elements[i], Value: *value}, nil) {
+					return
+				}
+			}
+			if scanEntry.Cursor == 0 {
+				return
+			}
+			cursor = scanEntry.Cursor
+		}
+	}
+}
+
+func (c *redisHashCache[Entity]) Set(
+	ctx context.Context,
+	key string,
+	value Entity,
+	retention time.Duration,
+) error {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("setting value of '%s' in hash cache '%s'", key, c.key)
+	data, err := marshalEntry(c.codec, c.codecEntry(key), value)
+	if err != nil {
+		return err
+	}
+	return c.set(ctx, map[string][]byte{key: data}, retention)
+}
+
+func (c *redisHashCache[Entity]) Get(
+	ctx context.Context,
+	key string,
+) (*Entity, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("fetching value of '%s' from hash cache '%s'", key, c.key)
+	return c.decodeResult(key, c.read(ctx, c.client.B().Hget().Key(c.key).Field(key).Build(), key))
+}
+
+func (c *redisHashCache[Entity]) Remove(
+	ctx context.Context,
+	key string,
+) error {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("removing value of '%s' from hash cache '%s'", key, c.key)
+	return c.client.Do(ctx, c.client.B().Hdel().Key(c.key).Field(key).Build()).Error()
+}
+
+func (c *redisHashCache[Entity]) GetMany(
+	ctx context.Context,
+	keys []string,
+) (map[string]Entity, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("fetching values of %d keys from hash cache '%s'", len(keys), c.key)
+	values := make(map[string]Entity)
+	if len(keys) == 0 {
+		return values, nil
+	}
+	messages, err := c.read(ctx, c.client.B().Hmget().Key(c.key).Field(keys...).Build(), keys...).ToArray()
+	if err != nil {
+		return nil, err
+	}
+
+	errs := make(map[string]error)
+	for i, message := range messages {
+		if message.IsNil() {
+			continue
+		}
+		value, innerErr := c.decodeMessage(keys[i], message)
+		if innerErr != nil {
+			errs[keys[i]] = innerErr
+			continue
+		}
+		values[keys[i]] = *value
+	}
+	return values, batchError(errs)
+}
+
+// SetMany writes all entries in one transaction, so apart from encoding failures either all or none are stored.
+func (c *redisHashCache[Entity]) SetMany(
+	ctx context.Context,
+	entries map[string]Entity,
+	retention time.Duration,
+) error {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("setting values of %d keys in hash cache '%s'", len(entries), c.key)
+	errs := make(map[string]error)
+	fieldValues := make(map[string][]byte, len(entries))
+	for key, value := range entries {
+		data, err := marshalEntry(c.codec, c.codecEntry(key), value)
+		if err != nil {
+			errs[key] = err
+			continue
+		}
+		fieldValues[key] = data
+	}
+	if err := c.set(ctx, fieldValues, retention); err != nil {
+		return err
+	}
+	return batchError(errs)
+}
+
+// set writes the encoded field values and their expiry in one transaction
+func (c *redisHashCache[Entity]) set(
+	ctx context.Context,
+	fieldValues map[string][]byte,
+	retention time.Duration,
+) error {
+	if len(fieldValues) == 0 {
+		return nil
+	}
+	cmd := c.client.B().Hset().Key(c.key).FieldValue()
+	fields := make([]string, 0, len(fieldValues))
+	for field, data := range fieldValues {
+		cmd = cmd.FieldValue(field, rueidis.BinaryString(data))
+		fields = append(fields, field)
+	}
+	return c.exec(ctx, c.client.B(), c.writeCommands(c.client.B(), cmd.Build(), fields, retention))
+}
+
+func (c *redisHashCache[Entity]) RemoveMany(
+	ctx context.Context,
+	keys []string,
+) error {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("removing values of %d keys from hash cache '%s'", len(keys), c.key)
+	if len(keys) == 0 {
+		return nil
+	}
+	return c.client.Do(ctx, c.client.B().Hdel().Key(c.key).Field(keys...).Build()).Error()
+}
+
+// Clear unlinks the hash, removing all its entries atomically, followed by the hashes of all namespaces.
+func (c *redisHashCache[Entity]) Clear(
+	ctx context.Context,
+) error {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("clearing hash cache '%s'", c.key)
+	// the hashes of the namespaces are collected before anything is deleted, there is one per namespace
+	keys := []string{c.key}
+	for batch, err := range scanKeys(ctx, c.client, namespacePattern(c.key), c.config.ScanCount) {
+		if err != nil {
+			return err
+		}
+		keys = append(keys, batch...)
+	}
+	cmds := make(rueidis.Commands, 0, len(keys))
+	for _, key := range keys {
+		cmds = append(cmds, c.client.B().Unlink().Key(key).Build())
+	}
+	for _, result := range c.client.DoMulti(ctx, cmds...) {
+		if err := result.Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *redisHashCache[Entity]) SetIfAbsent(
+	ctx context.Context,
+	key string,
+	value Entity,
+	retention time.Duration,
+) (bool, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("setting value of '%s' in hash cache '%s' if absent", key, c.key)
+	data, err := marshalEntry(c.codec, c.codecEntry(key), value)
+	if err != nil {
+		return false, err
+	}
+	stored, err := setIfAbsentHashScript.Exec(ctx, c.client, []string{c.key}, []string{
+		key,
+		rueidis.BinaryString(data),
+		strconv.FormatInt(scriptExpiryMillis(c.fieldRetention(retention)), 10),
+	}).AsInt64()
+	if err != nil {
+		return false, err
+	}
+	return stored == 1, nil
+}
+
+func (c *redisHashCache[Entity]) CompareAndSwap(
+	ctx context.Context,
+	key string,
+	oldValue Entity,
+	newValue Entity,
+	retention time.Duration,
+) (bool, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("swapping value of '%s' in hash cache '%s'", key, c.key)
+	data, err := marshalEntry(c.codec, c.codecEntry(key), newValue)
+	if err != nil {
+		return false, err
+	}
+	return c.watchedExec(ctx, key, func(current *Entity) bool {
+		return current != nil && valuesEqual(*current, oldValue)
+	}, func(builder rueidis.Builder) rueidis.Commands {
+		cmd := builder.Hset().Key(c.key).FieldValue().FieldValue(key, rueidis.BinaryString(data)).Build()
+		return c.writeCommands(builder, cmd, []string{key}, retention)
+	})
+}
+
+func (c *redisHashCache[Entity]) RemoveIfEquals(
+	ctx context.Context,
+	key string,
+	value Entity,
+) (bool, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("removing value of '%s' from hash cache '%s' if unchanged", key, c.key)
+	return c.watchedExec(ctx, key, func(current *Entity) bool {
+		return current != nil && valuesEqual(*current, value)
+	}, func(builder rueidis.Builder) rueidis.Commands {
+		return rueidis.Commands{builder.Hdel().Key(c.key).Field(key).Build()}
+	})
+}
+
+func (c *redisHashCache[Entity]) RemainingRetention(
+	ctx context.Context,
+	key string,
+) (time.Duration, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("fetching remaining retention of '%s' hash cache '%s'", key, c.key)
+	if !c.config.FieldExpiry {
+		exists, err := c.client.Do(ctx, c.client.B().Hexists().Key(c.key).Field(key).Build()).AsBool()
+		if err != nil || !exists {
+			return 0, err
+		}
+		return math.MaxInt64, nil
+	}
+
+	ttls, err := c.client.Do(ctx, c.client.B().Hpttl().Key(c.key).Fields().Numfields(1).Field(key).Build()).AsIntSlice()
+	if err != nil {
+		return 0, err
+	}
+	if len(ttls) == 0 {
+		return 0, nil
+	}
+	return retentionFromPTTL(ttls[0]), nil
+}
+
+// Touch uses HPEXPIRE or HPERSIST if field expiry is enabled, otherwise retentions are ignored and
+// only the presence of key is reported.
+func (c *redisHashCache[Entity]) Touch(
+	ctx context.Context,
+	key string,
+	retention time.Duration,
+) (bool, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("touching value of '%s' in hash cache '%s'", key, c.key)
+	if !c.config.FieldExpiry {
+		return c.client.Do(ctx, c.client.B().Hexists().Key(c.key).Field(key).Build()).AsBool()
+	}
+
+	var cmd rueidis.Completed
+	if retention > 0 {
+		cmd = c.expireCommand(c.client.B(), retention, key)
+	} else {
+		cmd = c.client.B().Hpersist().Key(c.key).Fields().Numfields(1).Field(key).Build()
+	}
+	codes, err := c.client.Do(ctx, cmd).AsIntSlice()
+	if err != nil {
+		return false, err
+	}
+	// both commands reply -2 for missing fields
+	return len(codes) > 0 && codes[0] != -2, nil
+}
+
+// WithNamespace returns a view whose entries are stored in the hash '<key>#ns:<namespace>'.
+func (c *redisHashCache[Entity]) WithNamespace(
+	namespace string,
+) Cache[Entity] {
+	return &redisHashCache[Entity]{
+		client: c.client,
+		key:    namespaceKey(c.key, namespace),
+		codec:  c.codec,
+		config: c.config,
+	}
+}
+
+func (c *redisHashCache[Entity]) Namespaces(
+	ctx context.Context,
+) ([]string, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("fetching namespaces of hash cache '%s'", c.key)
+	return scanNamespaces(c.key, scanKeys(ctx, c.client, namespacePattern(c.key), c.config.ScanCount))
+}
+
+// writeCommands complements the write cmd of the given fields by their expiry, if enabled
+func (c *redisHashCache[Entity]) writeCommands(
+	builder rueidis.Builder,
+	cmd rueidis.Completed,
+	fields []string,
+	retention time.Duration,
+) rueidis.Commands {
+	cmds := rueidis.Commands{cmd}
+	if fieldRetention := c.fieldRetention(retention); fieldRetention > 0 {
+		cmds = append(cmds, c.expireCommand(builder, fieldRetention, fields...))
+	}
+	return cmds
+}
+
+func (c *redisHashCache[Entity]) expireCommand(
+	builder rueidis.Builder,
+	retention time.Duration,
+	fields ...string,
+) rueidis.Completed {
+	return builder.Hpexpire().Key(c.key).Milliseconds(expiryMillis(retention)).
+		Fields().Numfields(int64(len(fields))).Field(fields...).Build()
+}
+
+// read runs the read cmd of the given fields, which is pipelined with extending their retention in sliding mode
+func (c *redisHashCache[Entity]) read(
+	ctx context.Context,
+	cmd rueidis.Completed,
+	fields ...string,
+) rueidis.RedisResult {
+	if c.fieldRetention(c.config.SlidingRetention) <= 0 {
+		return c.client.Do(ctx, cmd)
+	}
+	results := c.client.DoMulti(ctx, cmd, c.expireCommand(c.client.B(), c.config.SlidingRetention, fields...))
+	if err := results[1].Error(); err != nil {
+		return results[1]
+	}
+	return results[0]
+}
+
+// fieldRetention returns the retention applied to fields, zero if field expiry is disabled
+func (c *redisHashCache[Entity]) fieldRetention(retention time.Duration) time.Duration {
+	if !c.config.FieldExpiry || retention <= 0 {
+		return 0
+	}
+	return retention
+}
+
+// exec runs cmds within MULTI/EXEC, a single command is sent as it is
+func (c *redisHashCache[Entity]) exec(
+	ctx context.Context,
+	builder rueidis.Builder,
+	cmds rueidis.Commands,
+) error {
+	if len(cmds) == 1 {
+		return c.client.Do(ctx, cmds[0]).Error()
+	}
+	transaction := append(append(rueidis.Commands{builder.Multi().Build()}, cmds...), builder.Exec().Build())
+	for _, result := range c.client.DoMulti(ctx, transaction...) {
+		if err := result.Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// watchedExec runs the commands created by build in a transaction if condition holds for the current
+// value of key. As WATCH covers the whole hash, a transaction aborted by a concurrent modification
+// is retried with growing backoff as long as the condition still holds, for at most
+// watchedExecAttempts attempts. Running out of attempts is reported as not executed.
+func (c *redisHashCache[Entity]) watchedExec(
+	ctx context.Context,
+	key string,
+	condition func(current *Entity) bool,
+	build func(builder rueidis.Builder) rueidis.Commands,
+) (bool, error) {
+	backoff := watchedExecBackoff
+	for attempt := 1; ; attempt++ {
+		executed, aborted := false, false
+		err := c.client.Dedicated(func(client rueidis.DedicatedClient) error {
+			if err := client.Do(ctx, client.B().Watch().Key(c.key).Build()).Error(); err != nil {
+				return err
+			}
+			current, err := c.decodeResult(key, client.Do(ctx, client.B().Hget().Key(c.key).Field(key).Build()))
+			if err != nil || !condition(current) {
+				if unwatchErr := client.Do(ctx, client.B().Unwatch().Build()).Error(); unwatchErr != nil {
+					return errors.Join(err, unwatchErr)
+				}
+				return err
+			}
+
+			cmds := append(append(rueidis.Commands{client.B().Multi().Build()}, build(client.B())...), client.B().Exec().Build())
+			results := client.DoMulti(ctx, cmds...)
+			if err = results[len(results)-1].Error(); err != nil {
+				if rueidis.IsRedisNil(err) {
+					aborted = true
+					return nil
+				}
+				return err
+			}
+			executed = true
+			return nil
+		})
+		if err != nil || !aborted {
+			return executed, err
+		}
+		if attempt >= watchedExecAttempts {
+			aulogging.Logger.Ctx(ctx).Debug().
+				Printf("giving up conditional write of '%s' in hash cache '%s' after %d attempts", key, c.key, attempt)
+			return false, nil
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false, ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// decodeResult decodes the reply of a HGET command, nil is returned for missing fields
+func (c *redisHashCache[Entity]) decodeResult(key string, result rueidis.RedisResult) (*Entity, error) {
+	if err := result.Error(); err != nil {
+		if rueidis.IsRedisNil(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	data, err := result.AsBytes()
+	if err != nil {
+		return nil, err
+	}
+	return decodeEntry[Entity](c.codec, c.codecEntry(key), data)
+}
+
+func (c *redisHashCache[Entity]) decodeMessage(key string, message rueidis.RedisMessage) (*Entity, error) {
+	data, err := message.AsBytes()
+	if err != nil {
+		return nil, err
+	}
+	return decodeEntry[Entity](c.codec, c.codecEntry(key), data)
+}
+
+// codecEntry identifies the field key to the codec
+func (c *redisHashCache[Entity]) codecEntry(key string) string {
+	return fmt.Sprintf("%s|%s", c.key, key)
+}
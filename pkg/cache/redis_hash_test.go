@@ -0,0 +1,171 @@
+package cache
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/redis/rueidis"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisHashCacheFieldRetention(t *testing.T) {
+	cut := &redisHashCache[demoEntity]{}
+	require.Equal(t, time.Duration(0), cut.fieldRetention(time.Minute))
+
+	cut.config.FieldExpiry = true
+	require.Equal(t, time.Minute, cut.fieldRetention(time.Minute))
+	require.Equal(t, time.Duration(0), cut.fieldRetention(0))
+	require.Equal(t, time.Duration(0), cut.fieldRetention(-time.Minute))
+}
+
+func TestRedisHashCacheWriteCommands(t *testing.T) {
+	client, _ := newFakeRedisClient(t, func([]string) string { return "+OK\r\n" })
+	builder := client.B()
+	cut := &redisHashCache[demoEntity]{key: "hash"}
+	write := func() rueidis.Completed {
+		return builder.Hset().Key("hash").FieldValue().FieldValue("a", "1").Build()
+	}
+
+	cmds := cut.writeCommands(builder, write(), []string{"a"}, time.Minute)
+	require.Len(t, cmds, 1)
+	require.Equal(t, []string{"HSET", "hash", "a", "1"}, cmds[0].Commands())
+
+	cut.config.FieldExpiry = true
+	cmds = cut.writeCommands(builder, write(), []string{"a", "b"}, time.Minute)
+	require.Len(t, cmds, 2)
+	require.Equal(t, []string{"HPEXPIRE", "hash", "60000", "FIELDS", "2", "a", "b"}, cmds[1].Commands())
+
+	cmds = cut.writeCommands(builder, write(), []string{"a"}, 0)
+	require.Len(t, cmds, 1)
+}
+
+func TestRedisHashCacheDecodeResult(t *testing.T) {
+	client, _ := newFakeRedisClient(t, func(command []string) string {
+		switch command[2] {
+		case "present":
+			return bulkReply(`{"v1":"value1"}`)
+		case "malformed":
+			return bulkReply(`{`)
+		case "failing":
+			return "-ERR failure\r\n"
+		default:
+			return "_\r\n"
+		}
+	})
+	ctx := context.Background()
+	cut := NewRedisHashCacheFromClient[demoEntity](client, "hash", nil).(*redisHashCache[demoEntity])
+
+	value, err := cut.decodeResult("present", client.Do(ctx, client.B().Hget().Key("hash").Field("present").Build()))
+	require.Nil(t, err)
+	require.Equal(t, "value1", value.Value1)
+
+	value, err = cut.decodeResult("missing", client.Do(ctx, client.B().Hget().Key("hash").Field("missing").Build()))
+	require.Nil(t, err)
+	require.Nil(t, value)
+
+	_, err = cut.decodeResult("malformed", client.Do(ctx, client.B().Hget().Key("hash").Field("malformed").Build()))
+	require.NotNil(t, err)
+
+	_, err = cut.decodeResult("failing", client.Do(ctx, client.B().Hget().Key("hash").Field("failing").Build()))
+	require.NotNil(t, err)
+	require.False(t, rueidis.IsRedisNil(err))
+}
+
+func TestRedisHashCacheWithoutFieldExpiry(t *testing.T) {
+	client, fake := newFakeRedisClient(t, func(command []string) string {
+		if command[0] == "HEXISTS" && command[2] == "present" {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	})
+	ctx := context.Background()
+	cut := NewRedisHashCacheFromClient[demoEntity](client, "hash", nil)
+
+	retention, err := cut.RemainingRetention(ctx, "present")
+	require.Nil(t, err)
+	require.Equal(t, time.Duration(math.MaxInt64), retention)
+	retention, err = cut.RemainingRetention(ctx, "missing")
+	require.Nil(t, err)
+	require.Equal(t, time.Duration(0), retention)
+
+	touched, err := cut.Touch(ctx, "present", time.Minute)
+	require.Nil(t, err)
+	require.True(t, touched)
+	touched, err = cut.Touch(ctx, "missing", time.Minute)
+	require.Nil(t, err)
+	require.False(t, touched)
+
+	// retentions are ignored, so neither HPTTL nor HPEXPIRE is sent
+	for _, command := range fake.recorded() {
+		require.Equal(t, "HEXISTS", command[0])
+	}
+}
+
+func TestRedisHashCacheWithFieldExpiry(t *testing.T) {
+	client, fake := newFakeRedisClient(t, func(command []string) string {
+		if command[len(command)-1] == "missing" {
+			return "*1\r\n:-2\r\n"
+		}
+		switch command[0] {
+		case "HPTTL":
+			return "*1\r\n:1500\r\n"
+		default:
+			return "*1\r\n:1\r\n"
+		}
+	})
+	ctx := context.Background()
+	cut := NewRedisHashCacheFromClient[demoEntity](client, "hash", &RedisHashCacheConfig{FieldExpiry: true})
+
+	retention, err := cut.RemainingRetention(ctx, "present")
+	require.Nil(t, err)
+	require.Equal(t, 1500*time.Millisecond, retention)
+	retention, err = cut.RemainingRetention(ctx, "missing")
+	require.Nil(t, err)
+	require.Equal(t, time.Duration(0), retention)
+
+	touched, err := cut.Touch(ctx, "present", time.Minute)
+	require.Nil(t, err)
+	require.True(t, touched)
+	touched, err = cut.Touch(ctx, "present", 0)
+	require.Nil(t, err)
+	require.True(t, touched)
+	touched, err = cut.Touch(ctx, "missing", time.Minute)
+	require.Nil(t, err)
+	require.False(t, touched)
+
+	commands := fake.recorded()
+	require.Equal(t, []string{"HPEXPIRE", "hash", "60000", "FIELDS", "1", "present"}, commands[2])
+	require.Equal(t, []string{"HPERSIST", "hash", "FIELDS", "1", "present"}, commands[3])
+}
+
+func TestRedisHashCacheCompareAndSwapGivesUp(t *testing.T) {
+	client, fake := newFakeRedisClient(t, func(command []string) string {
+		switch command[0] {
+		case "HGET":
+			return bulkReply(`{"v1":"old"}`)
+		case "EXEC":
+			// every transaction is aborted by a concurrent modification
+			return "_\r\n"
+		case "HSET", "HPEXPIRE":
+			return "+QUEUED\r\n"
+		default:
+			return "+OK\r\n"
+		}
+	})
+	ctx := context.Background()
+	cut := NewRedisHashCacheFromClient[demoEntity](client, "hash", nil)
+
+	swapped, err := cut.CompareAndSwap(ctx, "key", demoEntity{Value1: "old"}, demoEntity{Value1: "new"}, 0)
+	require.Nil(t, err)
+	require.False(t, swapped)
+
+	execs := 0
+	for _, command := range fake.recorded() {
+		if command[0] == "EXEC" {
+			execs++
+		}
+	}
+	require.Equal(t, watchedExecAttempts, execs)
+}
@@ -0,0 +1,297 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/redis/rueidis"
+)
+
+type KeyEncoding int64
+
+const (
+	// KeyEncodingRaw stores entry names unchanged as done by earlier versions, names must not contain
+	// '|' or glob characters then
+	KeyEncodingRaw KeyEncoding = iota
+	// KeyEncodingEscaped percent-escapes all characters of entry names that have a meaning in keys or
+	// glob patterns. Names without such characters are stored unchanged, entries written with
+	// KeyEncodingRaw under other names require MigrateRedisCacheKeys.
+	KeyEncodingEscaped
+)
+
+const (
+	escapedNameCharacters = "%|*?[]\\/#"
+	globCharacters        = "*?[]\\"
+	hashedNamePrefix      = "#"
+)
+
+// escapeName percent-escapes the characters separating keys and namespaces, introducing hashed names
+// and those special to glob patterns, so any name can be embedded into a key and matched by a pattern
+func escapeName(name string) string {
+	var builder strings.Builder
+	for i := 0; i < len(name); i++ {
+		if strings.IndexByte(escapedNameCharacters, name[i]) >= 0 {
+			_, _ = fmt.Fprintf(&builder, "%%%02X", name[i])
+		} else {
+			builder.WriteByte(name[i])
+		}
+	}
+	return builder.String()
+}
+
+func unescapeName(escaped string) (string, error) {
+	return url.PathUnescape(escaped)
+}
+
+// isEscapedName reports whether name is the escaped form of some name, which is ambiguous for legacy
+// names containing valid escape sequences
+func isEscapedName(name string) bool {
+	unescaped, err := unescapeName(name)
+	return err == nil && escapeName(unescaped) == name
+}
+
+// escapeGlob escapes s so that it matches itself literally within a SCAN pattern
+func escapeGlob(s string) string {
+	var builder strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(globCharacters, s[i]) >= 0 {
+			builder.WriteByte('\\')
+		}
+		builder.WriteByte(s[i])
+	}
+	return builder.String()
+}
+
+// encodeName maps an entry name onto the part of its key following the prefix of the cache
+func (c *redisCache[Entity]) encodeName(name string) string {
+	if c.config.KeyEncoding == KeyEncodingRaw {
+		return name
+	}
+	escaped := escapeName(name)
+	if c.config.HashNamesLongerThan > 0 && len(escaped) > c.config.HashNamesLongerThan {
+		return hashedNamePrefix + hashName(name)
+	}
+	return escaped
+}
+
+// entryNames maps the given entry keys onto their names, hashed names are resolved using the names hash.
+// Keys that cannot be decoded are logged and left out.
+func (c *redisCache[Entity]) entryNames(
+	ctx context.Context,
+	entryKeys []string,
+) (map[string]string, error) {
+	names := make(map[string]string, len(entryKeys))
+	hashed := make(map[string]string)
+	for _, entryKey := range entryKeys {
+		encoded := strings.TrimPrefix(entryKey, c.entryKeyPrefix())
+		if c.config.KeyEncoding == KeyEncodingRaw {
+			names[entryKey] = encoded
+			continue
+		}
+		if strings.HasPrefix(encoded, hashedNamePrefix) {
+			hashed[strings.TrimPrefix(encoded, hashedNamePrefix)] = entryKey
+			continue
+		}
+		name, err := unescapeName(encoded)
+		if err != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err).
+				Printf("skipping key '%s' of cache '%s' with invalid encoding", entryKey, c.key)
+			continue
+		}
+		names[entryKey] = name
+	}
+	if len(hashed) == 0 {
+		return names, nil
+	}
+
+	hashes := make([]string, 0, len(hashed))
+	for hash := range hashed {
+		hashes = append(hashes, hash)
+	}
+	messages, err := c.client.Do(ctx, c.client.B().Hmget().Key(c.namesKey()).Field(hashes...).Build()).ToArray()
+	if err != nil {
+		return nil, err
+	}
+	for i, message := range messages {
+		name, err := message.ToString()
+		if err != nil {
+			aulogging.Logger.Ctx(ctx).Warn().
+				Printf("skipping key '%s' of cache '%s' with unknown hashed name", hashed[hashes[i]], c.key)
+			continue
+		}
+		names[hashed[hashes[i]]] = name
+	}
+	return names, nil
+}
+
+// recordNames stores the original names of those names that are hashed, so that they can be listed
+func (c *redisCache[Entity]) recordNames(
+	ctx context.Context,
+	names ...string,
+) error {
+	cmd := c.client.B().Hset().Key(c.namesKey()).FieldValue()
+	recorded := false
+	for _, name := range names {
+		if encoded := c.encodeName(name); strings.HasPrefix(encoded, hashedNamePrefix) {
+			cmd = cmd.FieldValue(strings.TrimPrefix(encoded, hashedNamePrefix), name)
+			recorded = true
+		}
+	}
+	if !recorded {
+		return nil
+	}
+	return c.client.Do(ctx, cmd.Build()).Error()
+}
+
+// forgetNames removes the original names of those removed names that are hashed. A removal racing with
+// a write of the same name might drop the name recorded by the write, leaving the entry out of listings
+// until it is written again.
+func (c *redisCache[Entity]) forgetNames(
+	ctx context.Context,
+	names ...string,
+) error {
+	hashes := make([]string, 0)
+	for _, name := range names {
+		if encoded := c.encodeName(name); strings.HasPrefix(encoded, hashedNamePrefix) {
+			hashes = append(hashes, strings.TrimPrefix(encoded, hashedNamePrefix))
+		}
+	}
+	if len(hashes) == 0 {
+		return nil
+	}
+	return c.client.Do(ctx, c.client.B().Hdel().Key(c.namesKey()).Field(hashes...).Build()).Error()
+}
+
+// namesKey is the hash mapping hashed names onto the original ones, it is not matched by the entry pattern
+func (c *redisCache[Entity]) namesKey() string {
+	return fmt.Sprintf("%s#names", c.key)
+}
+
+func hashName(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:])
+}
+
+func isNameHash(s string) bool {
+	decoded, err := hex.DecodeString(s)
+	return err == nil && len(decoded) == sha256.Size && s == strings.ToLower(s)
+}
+
+// MigrateRedisCacheKeys rewrites the entries of the Redis cache key that were stored with unescaped names
+// with KeyEncodingRaw to the key encoding of config, and returns the number of migrated entries. Nothing is
+// migrated unless config selects KeyEncodingEscaped.
+// Entries already present under the new key take precedence. As legacy names containing '|' cannot be told
+// apart from entries of a cache whose key shares the prefix, such caches must not exist in the same database.
+// Names that already form valid escape sequences, like 'a%2A', are considered escaped, and names of a '#'
+// followed by a SHA-256 hash recorded in the names hash are considered hashed.
+func MigrateRedisCacheKeys(
+	ctx context.Context,
+	client rueidis.Client,
+	key string,
+	config *RedisCacheConfig,
+) (int, error) {
+	c := NewRedisCacheFromClient[[]byte](client, key, config).(*redisCache[[]byte])
+	if c.config.KeyEncoding == KeyEncodingRaw {
+		return 0, nil
+	}
+	aulogging.Logger.Ctx(ctx).Info().Printf("migrating key encoding of cache '%s'", key)
+
+	migrated := 0
+	for batch, err := range c.scan(ctx, c.entryKeyPattern()) {
+		if err != nil {
+			return migrated, err
+		}
+		hashed, err := c.recordedHashedNames(ctx, batch)
+		if err != nil {
+			return migrated, err
+		}
+		for _, legacyKey := range batch {
+			name := strings.TrimPrefix(legacyKey, c.entryKeyPrefix())
+			if hashed[name] {
+				continue
+			}
+			if isEscapedName(name) {
+				// escaped names might still have to be hashed
+				name, _ = unescapeName(name)
+			}
+			if c.entryKey(name) == legacyKey {
+				continue
+			}
+			if err = c.migrateEntry(ctx, legacyKey, name); err != nil {
+				return migrated, err
+			}
+			migrated++
+		}
+	}
+	return migrated, nil
+}
+
+// recordedHashedNames returns the encoded names of entryKeys that are hashed names recorded in the names
+// hash. Legacy names merely starting with '#' are not among them.
+func (c *redisCache[Entity]) recordedHashedNames(
+	ctx context.Context,
+	entryKeys []string,
+) (map[string]bool, error) {
+	hashes := make([]string, 0)
+	for _, entryKey := range entryKeys {
+		name := strings.TrimPrefix(entryKey, c.entryKeyPrefix())
+		if hash, ok := strings.CutPrefix(name, hashedNamePrefix); ok && isNameHash(hash) {
+			hashes = append(hashes, hash)
+		}
+	}
+	hashed := make(map[string]bool)
+	if len(hashes) == 0 {
+		return hashed, nil
+	}
+	messages, err := c.client.Do(ctx, c.client.B().Hmget().Key(c.namesKey()).Field(hashes...).Build()).ToArray()
+	if err != nil {
+		return nil, err
+	}
+	for i, message := range messages {
+		if !message.IsNil() {
+			hashed[hashedNamePrefix+hashes[i]] = true
+		}
+	}
+	return hashed, nil
+}
+
+func (c *redisCache[Entity]) migrateEntry(
+	ctx context.Context,
+	legacyKey string,
+	name string,
+) error {
+	results := c.client.DoMulti(ctx,
+		c.client.B().Get().Key(legacyKey).Build(),
+		c.client.B().Pttl().Key(legacyKey).Build(),
+	)
+	data, err := results[0].AsBytes()
+	if err != nil {
+		if rueidis.IsRedisNil(err) {
+			// entry expired or was removed since it was scanned
+			return nil
+		}
+		return err
+	}
+	ttlInMillis, err := results[1].AsInt64()
+	if err != nil {
+		return err
+	}
+
+	if err = c.recordNames(ctx, name); err != nil {
+		return err
+	}
+	cmd := c.client.B().Set().Key(c.entryKey(name)).Value(rueidis.BinaryString(data)).Nx()
+	if ttlInMillis > 0 {
+		cmd.Px(time.Duration(ttlInMillis) * time.Millisecond)
+	}
+	if err = c.client.Do(ctx, cmd.Build()).Error(); err != nil && !rueidis.IsRedisNil(err) {
+		return err
+	}
+	return c.client.Do(ctx, c.client.B().Del().Key(legacyKey).Build()).Error()
+}
@@ -0,0 +1,175 @@
+package cache
+
+import (
+	"context"
+	"iter"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscapeName(t *testing.T) {
+	for _, name := range []string{"plain", "a|b", "glob*?[x]", `back\slash`, "a/b", "100%", "#hash", "ümlaut"} {
+		escaped := escapeName(name)
+		require.False(t, strings.ContainsAny(escaped, "|*?[]\\/#"), escaped)
+		require.True(t, isEscapedName(escaped))
+
+		unescaped, err := unescapeName(escaped)
+		require.Nil(t, err)
+		require.Equal(t, name, unescaped)
+	}
+	require.Equal(t, "plain", escapeName("plain"))
+	require.Equal(t, "a%7Cb", escapeName("a|b"))
+	require.False(t, isEscapedName("a|b"))
+	require.False(t, isEscapedName("100%"))
+}
+
+func TestEscapeGlob(t *testing.T) {
+	require.Equal(t, `cache\*\?\[1\]\\|`, escapeGlob(`cache*?[1]\|`))
+}
+
+func TestScanNamespaces(t *testing.T) {
+	batches := func(yield func([]string, error) bool) {
+		yield([]string{
+			"cache#ns:tenant%2Fa|key",
+			"cache#ns:tenant%2Fa#ns:nested|key",
+			"cache#ns:tenant-b#names",
+			"cache#ns:tenant-b|%2A",
+			"cache#ns:hash%23ns:",
+		}, nil)
+	}
+	namespaces, err := scanNamespaces("cache", iter.Seq2[[]string, error](batches))
+	require.Nil(t, err)
+	require.Equal(t, []string{"hash#ns:", "tenant-b", "tenant/a"}, namespaces)
+	require.Equal(t, "cache#ns:tenant%2Fa", namespaceKey("cache", "tenant/a"))
+	require.Equal(t, `cache\[1\]#ns:*`, namespacePattern("cache[1]"))
+}
+
+func TestRedisCacheEncodeName(t *testing.T) {
+	// by default, names are stored unchanged as done by earlier versions
+	legacy := NewRedisCacheFromClient[demoEntity](nil, "cache", nil).(*redisCache[demoEntity])
+	require.Equal(t, "cache|a/b|c", legacy.entryKey("a/b|c"))
+
+	cut := &redisCache[demoEntity]{key: "cache", config: RedisCacheConfig{KeyEncoding: KeyEncodingEscaped}}
+	require.Equal(t, "plain", cut.encodeName("plain"))
+	require.Equal(t, "a%2Fb", cut.encodeName("a/b"))
+	require.Equal(t, "cache|a%7Cb", cut.entryKey("a|b"))
+
+	cut.config.HashNamesLongerThan = 5
+	require.Equal(t, "short", cut.encodeName("short"))
+	// the threshold applies to the escaped name
+	require.Equal(t, hashedNamePrefix+hashName("a/b/c"), cut.encodeName("a/b/c"))
+	require.Equal(t, hashedNamePrefix+hashName("longer"), cut.encodeName("longer"))
+	require.Len(t, cut.encodeName(strings.Repeat("x", 1000)), len(hashedNamePrefix)+64)
+
+	cut.config.KeyEncoding = KeyEncodingRaw
+	require.Equal(t, "a/b|longer", cut.encodeName("a/b|longer"))
+}
+
+func TestRedisCacheEntryNames(t *testing.T) {
+	longName := strings.Repeat("long/", 10)
+	client, fake := newFakeRedisClient(t, func(command []string) string {
+		if command[0] != "HMGET" {
+			return "-ERR unexpected\r\n"
+		}
+		reply := "*" + strconv.Itoa(len(command)-2) + "\r\n"
+		for _, field := range command[2:] {
+			if field == hashName(longName) {
+				reply += bulkReply(longName)
+			} else {
+				reply += "_\r\n"
+			}
+		}
+		return reply
+	})
+	cut := NewRedisCacheFromClient[demoEntity](client, "cache", &RedisCacheConfig{KeyEncoding: KeyEncodingEscaped, HashNamesLongerThan: 20}).(*redisCache[demoEntity])
+
+	names, err := cut.entryNames(context.Background(), []string{
+		cut.entryKey("plain"),
+		cut.entryKey("a|b"),
+		cut.entryKey(longName),
+		"cache|#" + hashName("unknown"),
+		"cache|%zz",
+	})
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{
+		"cache|plain":                  "plain",
+		"cache|a%7Cb":                  "a|b",
+		"cache|#" + hashName(longName): longName,
+	}, names)
+	require.Equal(t, "cache#names", fake.recorded()[0][1])
+
+	// without hashed names, the names hash is not queried
+	names, err = cut.entryNames(context.Background(), []string{cut.entryKey("plain")})
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{"cache|plain": "plain"}, names)
+	require.Len(t, fake.recorded(), 1)
+}
+
+func TestRedisCacheRemoveForgetsHashedNames(t *testing.T) {
+	longName := strings.Repeat("long", 10)
+	client, fake := newFakeRedisClient(t, func([]string) string {
+		return ":1\r\n"
+	})
+	ctx := context.Background()
+	cut := NewRedisCacheFromClient[demoEntity](client, "cache", &RedisCacheConfig{KeyEncoding: KeyEncodingEscaped, HashNamesLongerThan: 20})
+
+	require.Nil(t, cut.Remove(ctx, "short"))
+	require.Nil(t, cut.RemoveMany(ctx, []string{"short", longName}))
+	require.Nil(t, cut.Remove(ctx, longName))
+
+	hdels := make([][]string, 0)
+	for _, command := range fake.recorded() {
+		if command[0] == "HDEL" {
+			hdels = append(hdels, command)
+		}
+	}
+	require.Equal(t, [][]string{
+		{"HDEL", "cache#names", hashName(longName)},
+		{"HDEL", "cache#names", hashName(longName)},
+	}, hdels)
+}
+
+func TestMigrateRedisCacheKeysSkipsRecordedHashedNames(t *testing.T) {
+	knownHash := hashName(strings.Repeat("long", 10))
+	unknownHash := hashName("unknown")
+	client, fake := newFakeRedisClient(t, func(command []string) string {
+		switch command[0] {
+		case "SCAN":
+			return "*2\r\n" + bulkReply("0") + "*3\r\n" +
+				bulkReply("cache|#"+knownHash) + bulkReply("cache|#"+unknownHash) + bulkReply("cache|#legacy")
+		case "HMGET":
+			reply := "*" + strconv.Itoa(len(command)-2) + "\r\n"
+			for _, field := range command[2:] {
+				if field == knownHash {
+					reply += bulkReply(strings.Repeat("long", 10))
+				} else {
+					reply += "_\r\n"
+				}
+			}
+			return reply
+		case "GET":
+			return bulkReply("{}")
+		case "PTTL":
+			return ":-1\r\n"
+		case "DEL":
+			return ":1\r\n"
+		default:
+			return "+OK\r\n"
+		}
+	})
+
+	migrated, err := MigrateRedisCacheKeys(context.Background(), client, "cache", &RedisCacheConfig{KeyEncoding: KeyEncodingEscaped})
+	require.Nil(t, err)
+	// legacy names starting with '#' are migrated unless they are hashed names recorded in the names hash
+	require.Equal(t, 2, migrated)
+	deleted := make([]string, 0)
+	for _, command := range fake.recorded() {
+		if command[0] == "DEL" {
+			deleted = append(deleted, command[1])
+		}
+	}
+	require.ElementsMatch(t, []string{"cache|#" + unknownHash, "cache|#legacy"}, deleted)
+}
@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/redis/rueidis"
+)
+
+// metadataMagic starts the header '<magic><created>:<updated>:<version>\n' preceding the values of caches
+// tracking metadata, with times in Unix milliseconds. JSON documents never start with a control character
+// and gob messages never with type id 0, so values written by these codecs, also compressed or encrypted,
+// cannot be mistaken for a header. Values of raw codecs starting with the magic are.
+const metadataMagic = "\x03\x00meta\x00"
+
+// setWithMetadataScript stores the value with a header keeping creation time and version of the previous
+// value, ARGV: value, current time in milliseconds, retention in milliseconds, metadata magic.
+// The reply is nil for absent keys.
+var setWithMetadataScript = rueidis.NewLuaScript(`
+local created, version, magic = ARGV[2], 1, ARGV[4]
+local previous = redis.call('GET', KEYS[1])
+if previous and string.sub(previous, 1, #magic) == magic then
+	local previousCreated, previousVersion = string.match(previous, '^(%d+):%d+:(%d+)\n', #magic + 1)
+	if previousCreated then
+		created, version = previousCreated, tonumber(previousVersion) + 1
+	end
+end
+local data = magic .. string.format('%s:%s:%d\n', created, ARGV[2], version) .. ARGV[1]
+if tonumber(ARGV[3]) > 0 then
+	redis.call('SET', KEYS[1], data, 'PX', ARGV[3])
+else
+	redis.call('SET', KEYS[1], data)
+end
+if previous then
+	return 1
+end
+return false
+`)
+
+// GetWithMetadata requires TrackMetadata. Values written before it was enabled are reported with
+// zero timestamps and version.
+func (c *redisCache[Entity]) GetWithMetadata(
+	ctx context.Context,
+	key string,
+) (*Entity, *EntryMetadata, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("fetching value and metadata of '%s' from cache '%s'", key, c.key)
+	if !c.config.TrackMetadata {
+		return nil, nil, NewErrUnsupportedOperation(string(OperationGetWithMetadata))
+	}
+	results := c.client.DoMulti(ctx,
+		c.getCommand(key),
+		c.client.B().Pttl().Key(c.entryKey(key)).Build(),
+	)
+	value, metadata, err := c.decodeEntry(key, results[0])
+	if err != nil || value == nil {
+		return nil, nil, err
+	}
+	ttlInMillis, err := results[1].AsInt64()
+	if err != nil {
+		return nil, nil, err
+	}
+	if metadata == nil {
+		metadata = &EntryMetadata{}
+	}
+	metadata.RemainingRetention = retentionFromPTTL(ttlInMillis)
+	return value, metadata, nil
+}
+
+// writeValues stores the encoded values, using the metadata script if metadata is tracked. Failures are
+// recorded in errs, the results of keys that were absent before are nil replies.
+func (c *redisCache[Entity]) writeValues(
+	ctx context.Context,
+	keys []string,
+	values [][]byte,
+	retention time.Duration,
+	errs map[string]error,
+) []rueidis.RedisResult {
+	if !c.config.TrackMetadata {
+		cmds := make(rueidis.Commands, 0, len(keys))
+		for i, key := range keys {
+			cmds = append(cmds, c.setCommand(key, values[i], retention))
+		}
+		return c.doMulti(ctx, keys, cmds, errs)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	execs := make([]rueidis.LuaExec, 0, len(keys))
+	for i, key := range keys {
+		execs = append(execs, rueidis.LuaExec{
+			Keys: []string{c.entryKey(key)},
+			Args: []string{
+				rueidis.BinaryString(values[i]),
+				now,
+				strconv.FormatInt(scriptExpiryMillis(retention), 10),
+				metadataMagic,
+			},
+		})
+	}
+	results := setWithMetadataScript.ExecMulti(ctx, c.client, execs...)
+	recordErrors(keys, results, errs)
+	return results
+}
+
+// withMetadata prepends the header for a value replacing one with the previous metadata, nil for new
+// entries, if metadata is tracked
+func (c *redisCache[Entity]) withMetadata(data []byte, previous *EntryMetadata) []byte {
+	if !c.config.TrackMetadata {
+		return data
+	}
+	now := time.Now()
+	createdAt, version := now, int64(1)
+	if previous != nil {
+		createdAt, version = previous.CreatedAt, previous.Version+1
+	}
+	header := fmt.Sprintf("%s%d:%d:%d\n", metadataMagic, createdAt.UnixMilli(), now.UnixMilli(), version)
+	return append([]byte(header), data...)
+}
+
+// splitMetadata separates the metadata header from the encoded value if metadata is tracked,
+// nil metadata is returned for values written without header
+func (c *redisCache[Entity]) splitMetadata(data []byte) (*EntryMetadata, []byte, error) {
+	if !c.config.TrackMetadata || !bytes.HasPrefix(data, []byte(metadataMagic)) {
+		return nil, data, nil
+	}
+	end := bytes.IndexByte(data, '\n')
+	if end < 0 {
+		return nil, nil, NewErrMalformedMetadata("header is not terminated")
+	}
+	fields := strings.Split(string(data[len(metadataMagic):end]), ":")
+	if len(fields) != 3 {
+		return nil, nil, NewErrMalformedMetadata("header does not consist of three fields")
+	}
+	numbers := make([]int64, len(fields))
+	for i, field := range fields {
+		number, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return nil, nil, NewErrMalformedMetadata(err.Error())
+		}
+		numbers[i] = number
+	}
+	return &EntryMetadata{
+		CreatedAt: time.UnixMilli(numbers[0]),
+		UpdatedAt: time.UnixMilli(numbers[1]),
+		Version:   numbers[2],
+	}, data[end+1:], nil
+}
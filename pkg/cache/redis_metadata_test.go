@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisMetadataHeader(t *testing.T) {
+	cut := &redisCache[demoEntity]{codec: NewJSONCodec(), config: RedisCacheConfig{TrackMetadata: true}}
+	data, err := cut.codec.Marshal(demoEntity{Value1: "value1"})
+	require.Nil(t, err)
+
+	stored := cut.withMetadata(data, nil)
+	value, metadata, err := cut.decodeData("key", stored)
+	require.Nil(t, err)
+	require.Equal(t, "value1", value.Value1)
+	require.Equal(t, int64(1), metadata.Version)
+	require.Equal(t, metadata.CreatedAt, metadata.UpdatedAt)
+
+	created := time.UnixMilli(time.Now().Add(-time.Hour).UnixMilli())
+	stored = cut.withMetadata(data, &EntryMetadata{CreatedAt: created, Version: 4})
+	_, metadata, err = cut.decodeData("key", stored)
+	require.Nil(t, err)
+	require.Equal(t, int64(5), metadata.Version)
+	require.True(t, created.Equal(metadata.CreatedAt))
+	require.True(t, metadata.UpdatedAt.After(created))
+
+	// values written before metadata was tracked have none
+	value, metadata, err = cut.decodeData("key", data)
+	require.Nil(t, err)
+	require.Equal(t, "value1", value.Value1)
+	require.Nil(t, metadata)
+
+	_, _, err = cut.decodeData("key", []byte(metadataMagic+"1:2\n{}"))
+	require.True(t, errors.As(err, &ErrMalformedMetadata{}))
+
+	// gob values written before metadata was tracked might start with the former single marker byte
+	gobCut := &redisCache[int]{codec: NewGobCodec(), config: RedisCacheConfig{TrackMetadata: true}}
+	for _, legacy := range []int{5, 1 << 20} {
+		data, err = gobCut.codec.Marshal(legacy)
+		require.Nil(t, err)
+		gobValue, gobMetadata, err := gobCut.decodeData("key", data)
+		require.Nil(t, err)
+		require.Equal(t, legacy, *gobValue)
+		require.Nil(t, gobMetadata)
+	}
+
+	untracked := &redisCache[demoEntity]{codec: NewJSONCodec()}
+	require.Equal(t, data, untracked.withMetadata(data, nil))
+}
@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisCacheTouchRoundsUpRetention(t *testing.T) {
+	client, fake := newFakeRedisClient(t, func([]string) string {
+		return ":1\r\n"
+	})
+	ctx := context.Background()
+	cut := NewRedisCacheFromClient[demoEntity](client, "cache", nil)
+
+	for _, retention := range []time.Duration{time.Microsecond, time.Millisecond + time.Microsecond, time.Minute} {
+		touched, err := cut.Touch(ctx, "key1", retention)
+		require.Nil(t, err)
+		require.True(t, touched)
+	}
+	require.Equal(t, [][]string{
+		{"PEXPIRE", "cache|key1", "1"},
+		{"PEXPIRE", "cache|key1", "2"},
+		{"PEXPIRE", "cache|key1", "60000"},
+	}, fake.recorded())
+}
+
+func TestRedisCacheSetRetentions(t *testing.T) {
+	client, fake := newFakeRedisClient(t, func(command []string) string {
+		switch command[0] {
+		case "EVALSHA":
+			return "_\r\n"
+		case "SCRIPT":
+			return bulkReply(command[2])
+		default:
+			return "+OK\r\n"
+		}
+	})
+	ctx := context.Background()
+	plain := NewRedisCacheFromClient[string](client, "plain", nil)
+	tracked := NewRedisCacheFromClient[string](client, "tracked", &RedisCacheConfig{TrackMetadata: true})
+
+	for _, cut := range []Cache[string]{plain, tracked} {
+		for _, retention := range []time.Duration{500 * time.Millisecond, time.Microsecond} {
+			require.Nil(t, cut.Set(ctx, "key1", "value1", retention))
+		}
+	}
+	evals := make([][]string, 0)
+	sets := make([][]string, 0)
+	for _, command := range fake.recorded() {
+		switch command[0] {
+		case "SET":
+			sets = append(sets, command)
+		case "EVALSHA":
+			evals = append(evals, command)
+		}
+	}
+	require.Equal(t, [][]string{
+		{"SET", "plain|key1", `"value1"`, "PX", "500"},
+		{"SET", "plain|key1", `"value1"`, "PX", "1"},
+	}, sets)
+	// EVALSHA <sha> 1 <key> <value> <now> <retention> <magic>
+	require.Len(t, evals, 2)
+	require.Equal(t, "500", evals[0][6])
+	require.Equal(t, "1", evals[1][6])
+}
+
+func TestRedisCacheWatchWaitsForSubscription(t *testing.T) {
+	confirm := make(chan struct{})
+	client, _ := newFakeRedisClient(t, func(command []string) string {
+		if command[0] != "SUBSCRIBE" {
+			return "-ERR unexpected\r\n"
+		}
+		<-confirm
+		return ">3\r\n" + bulkReply("subscribe") + bulkReply(command[1]) + ":1\r\n"
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cut := NewRedisCacheFromClient[demoEntity](client, "cache", &RedisCacheConfig{PublishEvents: true}).(WatchableCache[demoEntity])
+
+	watched := make(chan error, 1)
+	go func() {
+		_, err := cut.Watch(ctx)
+		watched <- err
+	}()
+	select {
+	case <-watched:
+		t.Fatal("watch returned before the subscription was confirmed")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(confirm)
+	require.Nil(t, <-watched)
+}
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"time"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+)
+
+// snapshotRecord is one line of a snapshot. Values are stored as JSON independent of the codec of the
+// exported cache, expiry is absolute so that the time passing until the import is accounted for.
+type snapshotRecord struct {
+	Key       string          `json:"key"`
+	Value     json.RawMessage `json:"value"`
+	ExpiresAt time.Time       `json:"expiresAt,omitzero"`
+}
+
+// ExportSnapshot writes all entries of cache together with their expiry to w as JSON lines.
+// The snapshot is not atomic, entries modified during the export may or may not be contained.
+func ExportSnapshot[Entity any](
+	ctx context.Context,
+	cache Cache[Entity],
+	w io.Writer,
+) error {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("exporting cache snapshot")
+	encoder := json.NewEncoder(w)
+	for entry, err := range cache.All(ctx) {
+		if err != nil {
+			return err
+		}
+		retention, err := cache.RemainingRetention(ctx, entry.Key)
+		if err != nil {
+			return err
+		}
+		if retention <= 0 {
+			// entry expired or was removed since it was listed
+			continue
+		}
+		value, err := json.Marshal(entry.Value)
+		if err != nil {
+			return err
+		}
+		record := snapshotRecord{Key: entry.Key, Value: value}
+		if retention != math.MaxInt64 {
+			record.ExpiresAt = time.Now().Add(retention)
+		}
+		if err = encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportSnapshot stores all entries of a snapshot written by ExportSnapshot in cache, keeping their
+// remaining retention, and returns the number of imported entries. Entries expired in the meantime are skipped.
+func ImportSnapshot[Entity any](
+	ctx context.Context,
+	cache Cache[Entity],
+	r io.Reader,
+) (int, error) {
+	aulogging.Logger.Ctx(ctx).Debug().Printf("importing cache snapshot")
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	imported := 0
+	for {
+		var record snapshotRecord
+		if err := decoder.Decode(&record); err != nil {
+			if errors.Is(err, io.EOF) {
+				return imported, nil
+			}
+			return imported, err
+		}
+
+		var retention time.Duration
+		if !record.ExpiresAt.IsZero() {
+			if retention = time.Until(record.ExpiresAt); retention <= 0 {
+				continue
+			}
+		}
+		var value Entity
+		if err := json.Unmarshal(record.Value, &value); err != nil {
+			return imported, err
+		}
+		if err := cache.Set(ctx, record.Key, value, retention); err != nil {
+			return imported, err
+		}
+		imported++
+	}
+}
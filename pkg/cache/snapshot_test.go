@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	ctx := context.TODO()
+	source := NewMemoryCache[demoEntity]()
+	e1 := demoEntity{Value1: "first", Value3: p(map[string]string{"k": "v"})}
+	e2 := demoEntity{Value1: "second"}
+	require.Nil(t, source.Set(ctx, "key1", e1, 0))
+	require.Nil(t, source.Set(ctx, "key2", e2, time.Hour))
+
+	var snapshot bytes.Buffer
+	require.Nil(t, ExportSnapshot(ctx, source, &snapshot))
+
+	target, err := NewDiskCache[demoEntity](t.TempDir())
+	require.Nil(t, err)
+	imported, err := ImportSnapshot(ctx, target, &snapshot)
+	require.Nil(t, err)
+	require.Equal(t, 2, imported)
+
+	entries, err := target.Entries(ctx)
+	require.Nil(t, err)
+	require.EqualValues(t, map[string]demoEntity{"key1": e1, "key2": e2}, entries)
+
+	retention, err := target.RemainingRetention(ctx, "key1")
+	require.Nil(t, err)
+	require.Equal(t, time.Duration(math.MaxInt64), retention)
+	retention, err = target.RemainingRetention(ctx, "key2")
+	require.Nil(t, err)
+	require.Greater(t, retention, 59*time.Minute)
+	require.LessOrEqual(t, retention, time.Hour)
+}
+
+func TestImportSnapshotSkipsExpired(t *testing.T) {
+	ctx := context.TODO()
+	snapshot := strings.NewReader(`{"key":"key1","value":"expired","expiresAt":"2000-01-01T00:00:00Z"}
+{"key":"key2","value":"kept"}
+`)
+
+	target := NewMemoryCache[string]()
+	imported, err := ImportSnapshot(ctx, target, snapshot)
+	require.Nil(t, err)
+	require.Equal(t, 1, imported)
+
+	keys, err := target.Keys(ctx)
+	require.Nil(t, err)
+	require.Equal(t, []string{"key2"}, keys)
+}
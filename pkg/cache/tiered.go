@@ -0,0 +1,490 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+)
+
+// tieredCache serves reads from a local cache and falls back to a remote cache shared by all instances.
+// Writes go to the remote cache first and are announced through an Invalidator so that every instance
+// drops its local copy.
+type tieredCache[Entity any] struct {
+	local       Cache[Entity]
+	remote      Cache[Entity]
+	invalidator Invalidator
+	config      TieredCacheConfig
+	// fills is shared by all namespace views, which are identified by namespace
+	fills     *localFills
+	namespace []string
+}
+
+// localFills tracks the reads of the remote cache that are in flight, so that a local copy is not stored
+// if the key was invalidated while reading it
+type localFills struct {
+	mu       sync.Mutex
+	inFlight map[string]*localFill
+	// clears counts the invalidations of whole namespaces, which invalidate all reads in flight
+	clears uint64
+}
+
+type localFill struct {
+	readers    int
+	generation uint64
+}
+
+// localFillToken captures the invalidation state at the start of a read
+type localFillToken struct {
+	key        string
+	fill       *localFill
+	generation uint64
+	clears     uint64
+}
+
+type TieredCacheConfig struct {
+	// LocalRetention caps how long an entry is kept in the local cache, a non-positive value means
+	// entries are kept locally as long as they are retained in the remote cache
+	LocalRetention time.Duration
+	// ResubscribeDelay is the pause between attempts to re-establish a failed invalidation subscription
+	ResubscribeDelay time.Duration
+}
+
+func CreateDefaultTieredCacheConfig() TieredCacheConfig {
+	return TieredCacheConfig{
+		LocalRetention:   1 * time.Minute,
+		ResubscribeDelay: 5 * time.Second,
+	}
+}
+
+// NewTieredCache creates a two-tier cache whose invalidation subscription lives until ctx is done.
+// The local cache should be bounded, e.g. by using NewMemoryCacheWithConfig with MaxEntries.
+func NewTieredCache[Entity any](
+	ctx context.Context,
+	local Cache[Entity],
+	remote Cache[Entity],
+	invalidator Invalidator,
+	config *TieredCacheConfig,
+) Cache[Entity] {
+	var vConfig TieredCacheConfig
+	if config != nil {
+		vConfig = *config
+	} else {
+		vConfig = CreateDefaultTieredCacheConfig()
+	}
+
+	c := &tieredCache[Entity]{
+		local:       local,
+		remote:      remote,
+		invalidator: invalidator,
+		config:      vConfig,
+		fills:       &localFills{inFlight: make(map[string]*localFill)},
+	}
+	go c.subscribe(ctx)
+	return c
+}
+
+func (c *tieredCache[Entity]) Entries(
+	ctx context.Context,
+) (map[string]Entity, error) {
+	return c.remote.Entries(ctx)
+}
+
+func (c *tieredCache[Entity]) Keys(
+	ctx context.Context,
+) ([]string, error) {
+	return c.remote.Keys(ctx)
+}
+
+func (c *tieredCache[Entity]) Values(
+	ctx context.Context,
+) ([]Entity, error) {
+	return c.remote.Values(ctx)
+}
+
+func (c *tieredCache[Entity]) All(
+	ctx context.Context,
+) iter.Seq2[Entry[Entity], error] {
+	return c.remote.All(ctx)
+}
+
+func (c *tieredCache[Entity]) Set(
+	ctx context.Context,
+	key string,
+	value Entity,
+	retention time.Duration,
+) error {
+	if err := c.remote.Set(ctx, key, value, retention); err != nil {
+		return err
+	}
+	return c.afterWrite(ctx, key)
+}
+
+func (c *tieredCache[Entity]) Get(
+	ctx context.Context,
+	key string,
+) (*Entity, error) {
+	if value, err := c.local.Get(ctx, key); err != nil {
+		return nil, err
+	} else if value != nil {
+		return value, nil
+	}
+
+	token := c.fills.begin(c.fillKey(c.namespace, key))
+	defer c.fills.end(token)
+	value, err := c.remote.Get(ctx, key)
+	if err != nil || value == nil {
+		return value, err
+	}
+	retention, err := c.remote.RemainingRetention(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if retention > 0 {
+		err = c.fills.fill(token, func() error {
+			return c.local.Set(ctx, key, *value, c.localRetention(retention))
+		})
+		if err != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err).
+				Printf("failed to store value of '%s' in local cache", key)
+		}
+	}
+	return value, nil
+}
+
+// GetWithMetadata is served by the remote cache, as the metadata of local copies is not maintained across instances.
+func (c *tieredCache[Entity]) GetWithMetadata(
+	ctx context.Context,
+	key string,
+) (*Entity, *EntryMetadata, error) {
+	metadataCache, ok := c.remote.(MetadataCache[Entity])
+	if !ok {
+		return nil, nil, NewErrUnsupportedOperation(string(OperationGetWithMetadata))
+	}
+	return metadataCache.GetWithMetadata(ctx, key)
+}
+
+func (c *tieredCache[Entity]) Remove(
+	ctx context.Context,
+	key string,
+) error {
+	if err := c.remote.Remove(ctx, key); err != nil {
+		return err
+	}
+	return c.afterWrite(ctx, key)
+}
+
+// GetMany serves locally cached keys and fetches the others from the remote cache without storing
+// them locally, as this would require querying the remaining retention of every single key.
+func (c *tieredCache[Entity]) GetMany(
+	ctx context.Context,
+	keys []string,
+) (map[string]Entity, error) {
+	values, err := c.local.GetMany(ctx, keys)
+	if err != nil {
+		values = make(map[string]Entity)
+	}
+	missingKeys := make([]string, 0)
+	for _, key := range keys {
+		if _, ok := values[key]; !ok {
+			missingKeys = append(missingKeys, key)
+		}
+	}
+	if len(missingKeys) == 0 {
+		return values, nil
+	}
+
+	remoteValues, err := c.remote.GetMany(ctx, missingKeys)
+	for key, value := range remoteValues {
+		values[key] = value
+	}
+	return values, err
+}
+
+func (c *tieredCache[Entity]) SetMany(
+	ctx context.Context,
+	entries map[string]Entity,
+	retention time.Duration,
+) error {
+	err := c.remote.SetMany(ctx, entries, retention)
+	return errors.Join(err, c.afterWrite(ctx, slices.Collect(maps.Keys(withoutFailedKeys(entries, err)))...))
+}
+
+// RemoveMany announces the removal of all keys that were removed from the remote cache, also if it fails
+// for some of them
+func (c *tieredCache[Entity]) RemoveMany(
+	ctx context.Context,
+	keys []string,
+) error {
+	err := c.remote.RemoveMany(ctx, keys)
+	requested := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		requested[key] = struct{}{}
+	}
+	return errors.Join(err, c.afterWrite(ctx, slices.Collect(maps.Keys(withoutFailedKeys(requested, err)))...))
+}
+
+func (c *tieredCache[Entity]) Clear(
+	ctx context.Context,
+) error {
+	if err := c.remote.Clear(ctx); err != nil {
+		return err
+	}
+	if err := c.local.Clear(ctx); err != nil {
+		return err
+	}
+	return c.invalidator.PublishClear(ctx)
+}
+
+func (c *tieredCache[Entity]) SetIfAbsent(
+	ctx context.Context,
+	key string,
+	value Entity,
+	retention time.Duration,
+) (bool, error) {
+	stored, err := c.remote.SetIfAbsent(ctx, key, value, retention)
+	if err != nil || !stored {
+		return stored, err
+	}
+	return true, c.afterWrite(ctx, key)
+}
+
+func (c *tieredCache[Entity]) CompareAndSwap(
+	ctx context.Context,
+	key string,
+	oldValue Entity,
+	newValue Entity,
+	retention time.Duration,
+) (bool, error) {
+	swapped, err := c.remote.CompareAndSwap(ctx, key, oldValue, newValue, retention)
+	if err != nil {
+		return false, err
+	}
+	if !swapped {
+		// the local copy is likely outdated if the comparison failed
+		return false, c.local.Remove(ctx, key)
+	}
+	return true, c.afterWrite(ctx, key)
+}
+
+func (c *tieredCache[Entity]) RemoveIfEquals(
+	ctx context.Context,
+	key string,
+	value Entity,
+) (bool, error) {
+	removed, err := c.remote.RemoveIfEquals(ctx, key, value)
+	if err != nil {
+		return false, err
+	}
+	if !removed {
+		return false, c.local.Remove(ctx, key)
+	}
+	return true, c.afterWrite(ctx, key)
+}
+
+func (c *tieredCache[Entity]) RemainingRetention(
+	ctx context.Context,
+	key string,
+) (time.Duration, error) {
+	return c.remote.RemainingRetention(ctx, key)
+}
+
+// Touch extends the retention in the remote cache and caps it for the local copy like reads do.
+// Reads served locally do not extend the retention of a remote cache in sliding mode, which is why
+// LocalRetention should be well below its sliding retention.
+func (c *tieredCache[Entity]) Touch(
+	ctx context.Context,
+	key string,
+	retention time.Duration,
+) (bool, error) {
+	touched, err := c.remote.Touch(ctx, key, retention)
+	if err != nil {
+		return false, err
+	}
+	if !touched {
+		return false, c.local.Remove(ctx, key)
+	}
+	if _, err = c.local.Touch(ctx, key, c.localRetention(retention)); err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("failed to touch value of '%s' in local cache", key)
+	}
+	return true, nil
+}
+
+// WithNamespace returns a view on the namespaces of the local and remote cache. Its invalidations are
+// received through the subscription of this cache, so views do not subscribe on their own.
+func (c *tieredCache[Entity]) WithNamespace(
+	namespace string,
+) Cache[Entity] {
+	return &tieredCache[Entity]{
+		local:       c.local.WithNamespace(namespace),
+		remote:      c.remote.WithNamespace(namespace),
+		invalidator: c.invalidator.WithNamespace(namespace),
+		config:      c.config,
+		fills:       c.fills,
+		namespace:   append(slices.Clone(c.namespace), namespace),
+	}
+}
+
+func (c *tieredCache[Entity]) Namespaces(
+	ctx context.Context,
+) ([]string, error) {
+	lister, ok := c.remote.(NamespaceLister)
+	if !ok {
+		return nil, NewErrUnsupportedOperation(string(OperationNamespaces))
+	}
+	return lister.Namespaces(ctx)
+}
+
+// afterWrite drops the local copies of keys written to the remote cache and announces the write. Written
+// values are not stored locally, as a copy stored after announcing the write could outlive the invalidation
+// of a concurrent write by another instance. They are cached again on the next read.
+func (c *tieredCache[Entity]) afterWrite(
+	ctx context.Context,
+	keys ...string,
+) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	fillKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		fillKeys = append(fillKeys, c.fillKey(c.namespace, key))
+	}
+	// reads in flight are invalidated before the local copies, see localFills.fill
+	c.fills.invalidate(fillKeys...)
+	return errors.Join(c.local.RemoveMany(ctx, keys), c.invalidator.Publish(ctx, keys...))
+}
+
+func (c *tieredCache[Entity]) localRetention(remoteRetention time.Duration) time.Duration {
+	if c.config.LocalRetention <= 0 {
+		return remoteRetention
+	}
+	if remoteRetention <= 0 || remoteRetention == math.MaxInt64 {
+		return c.config.LocalRetention
+	}
+	return min(remoteRetention, c.config.LocalRetention)
+}
+
+func (c *tieredCache[Entity]) subscribe(ctx context.Context) {
+	for {
+		err := c.invalidator.Subscribe(ctx, func(invalidation Invalidation) {
+			local := c.local
+			for _, namespace := range invalidation.Namespace {
+				local = local.WithNamespace(namespace)
+			}
+			// reads in flight are invalidated before the local copies, see localFills.fill
+			if invalidation.All {
+				c.fills.invalidateAll()
+				c.flush(ctx, local)
+				return
+			}
+			path := append(slices.Clone(c.namespace), invalidation.Namespace...)
+			keys := make([]string, 0, len(invalidation.Keys))
+			for _, key := range invalidation.Keys {
+				keys = append(keys, c.fillKey(path, key))
+			}
+			c.fills.invalidate(keys...)
+			if innerErr := local.RemoveMany(ctx, invalidation.Keys); innerErr != nil {
+				aulogging.Logger.Ctx(ctx).Warn().WithErr(innerErr).
+					Printf("failed to remove invalidated keys from local cache")
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).
+			Printf("invalidation subscription failed, dropping local cache and resubscribing")
+		// invalidations might have been missed while the subscription was down
+		c.fills.invalidateAll()
+		c.flush(ctx, c.local)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(c.config.ResubscribeDelay):
+		}
+	}
+}
+
+func (c *tieredCache[Entity]) flush(ctx context.Context, local Cache[Entity]) {
+	if err := local.Clear(ctx); err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("failed to drop local cache")
+	}
+}
+
+// fillKey identifies key within the namespace path across all views
+func (c *tieredCache[Entity]) fillKey(path []string, key string) string {
+	return strings.Join(append(slices.Clone(path), key), "\x00")
+}
+
+func (f *localFills) begin(key string) localFillToken {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fill, ok := f.inFlight[key]
+	if !ok {
+		fill = &localFill{}
+		f.inFlight[key] = fill
+	}
+	fill.readers++
+	return localFillToken{key: key, fill: fill, generation: fill.generation, clears: f.clears}
+}
+
+func (f *localFills) end(token localFillToken) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	token.fill.readers--
+	if token.fill.readers == 0 {
+		delete(f.inFlight, token.key)
+	}
+}
+
+// fill runs store unless the key of token was invalidated since the read began. As invalidations are
+// registered before the local copies are removed, a store that is not skipped is removed afterward.
+func (f *localFills) fill(token localFillToken, store func() error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if token.fill.generation != token.generation || f.clears != token.clears {
+		return nil
+	}
+	return store()
+}
+
+func (f *localFills) invalidate(keys ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, key := range keys {
+		if fill, ok := f.inFlight[key]; ok {
+			fill.generation++
+		}
+	}
+}
+
+func (f *localFills) invalidateAll() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.clears++
+}
+
+// withoutFailedKeys returns the entries whose keys are not reported as failed by the given batch error
+func withoutFailedKeys[Entity any](entries map[string]Entity, err error) map[string]Entity {
+	if err == nil {
+		return entries
+	}
+	var batchErr ErrBatch
+	if !errors.As(err, &batchErr) {
+		return map[string]Entity{}
+	}
+	result := make(map[string]Entity, len(entries))
+	for key, value := range entries {
+		if _, failed := batchErr.KeyErrors()[key]; !failed {
+			result[key] = value
+		}
+	}
+	return result
+}
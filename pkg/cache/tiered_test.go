@@ -0,0 +1,226 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func TestTieredCacheInvalidation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	remote := NewMemoryCache[demoEntity]()
+	invalidator := NewMemoryInvalidator()
+	localA := NewMemoryCache[demoEntity]()
+	localB := NewMemoryCache[demoEntity]()
+	cutA := NewTieredCache(ctx, localA, remote, invalidator, nil)
+	cutB := NewTieredCache(ctx, localB, remote, invalidator, nil)
+	waitForSubscribers(t, invalidator, 2)
+
+	e1 := demoEntity{Value1: "first"}
+	err := cutA.Set(ctx, "key1", e1, time.Hour)
+	require.Nil(t, err)
+
+	got, err := cutB.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.EqualValues(t, e1, *got)
+	got, err = localB.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.EqualValues(t, e1, *got)
+
+	retention, err := localB.RemainingRetention(ctx, "key1")
+	require.Nil(t, err)
+	require.LessOrEqual(t, retention, time.Minute)
+
+	e2 := demoEntity{Value1: "second"}
+	err = cutA.Set(ctx, "key1", e2, time.Hour)
+	require.Nil(t, err)
+
+	got, err = localB.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.Nil(t, got)
+	got, err = cutB.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.EqualValues(t, e2, *got)
+
+	err = cutA.Remove(ctx, "key1")
+	require.Nil(t, err)
+	got, err = cutB.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.Nil(t, got)
+}
+
+func TestTieredCacheClear(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	remote := NewMemoryCache[demoEntity]()
+	invalidator := NewMemoryInvalidator()
+	localA := NewMemoryCache[demoEntity]()
+	localB := NewMemoryCache[demoEntity]()
+	cutA := NewTieredCache(ctx, localA, remote, invalidator, nil)
+	cutB := NewTieredCache(ctx, localB, remote, invalidator, nil)
+	waitForSubscribers(t, invalidator, 2)
+
+	require.Nil(t, cutA.Set(ctx, "key1", demoEntity{Value1: "first"}, time.Hour))
+	_, err := cutB.Get(ctx, "key1")
+	require.Nil(t, err)
+
+	require.Nil(t, cutA.Clear(ctx))
+	for _, c := range []Cache[demoEntity]{remote, localA, localB} {
+		keys, err := c.Keys(ctx)
+		require.Nil(t, err)
+		require.Empty(t, keys)
+	}
+}
+
+// interceptedCache runs beforeRetention whenever the remaining retention of an entry is queried
+type interceptedCache[Entity any] struct {
+	Cache[Entity]
+	beforeRetention func()
+}
+
+func (c *interceptedCache[Entity]) RemainingRetention(ctx context.Context, key string) (time.Duration, error) {
+	c.beforeRetention()
+	return c.Cache.RemainingRetention(ctx, key)
+}
+
+func TestTieredCacheSkipsLocalFillInvalidatedDuringRead(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	remote := NewMemoryCache[demoEntity]()
+	invalidator := NewMemoryInvalidator()
+	localB := NewMemoryCache[demoEntity]()
+	cutA := NewTieredCache(ctx, NewMemoryCache[demoEntity](), remote, invalidator, nil)
+	intercepted := &interceptedCache[demoEntity]{Cache: remote, beforeRetention: func() {}}
+	cutB := NewTieredCache(ctx, localB, intercepted, invalidator, &TieredCacheConfig{LocalRetention: -1})
+	waitForSubscribers(t, invalidator, 2)
+
+	require.Nil(t, cutA.Set(ctx, "key1", demoEntity{Value1: "first"}, time.Hour))
+	intercepted.beforeRetention = func() {
+		require.Nil(t, cutA.Set(ctx, "key1", demoEntity{Value1: "second"}, time.Hour))
+	}
+
+	got, err := cutB.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.Equal(t, "first", got.Value1)
+	got, err = localB.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.Nil(t, got)
+
+	intercepted.beforeRetention = func() {}
+	got, err = cutB.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.Equal(t, "second", got.Value1)
+	got, err = localB.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.Equal(t, "second", got.Value1)
+}
+
+// interceptedInvalidator runs afterPublish whenever an invalidation was published
+type interceptedInvalidator struct {
+	Invalidator
+	afterPublish func()
+}
+
+func (i *interceptedInvalidator) Publish(ctx context.Context, keys ...string) error {
+	err := i.Invalidator.Publish(ctx, keys...)
+	i.afterPublish()
+	return err
+}
+
+func TestTieredCacheKeepsNoLocalCopyInvalidatedAfterPublish(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	remote := NewMemoryCache[demoEntity]()
+	invalidator := NewMemoryInvalidator()
+	localA := NewMemoryCache[demoEntity]()
+	intercepted := &interceptedInvalidator{Invalidator: invalidator, afterPublish: func() {}}
+	cutA := NewTieredCache(ctx, localA, remote, intercepted, &TieredCacheConfig{LocalRetention: -1})
+	cutB := NewTieredCache(ctx, NewMemoryCache[demoEntity](), remote, invalidator, nil)
+	waitForSubscribers(t, invalidator, 2)
+
+	// the write of B is announced after A published its own write but before A could fill its local cache
+	intercepted.afterPublish = func() {
+		intercepted.afterPublish = func() {}
+		require.Nil(t, cutB.Set(ctx, "key1", demoEntity{Value1: "second"}, time.Hour))
+	}
+	require.Nil(t, cutA.Set(ctx, "key1", demoEntity{Value1: "first"}, time.Hour))
+
+	got, err := localA.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.Nil(t, got)
+	got, err = cutA.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.Equal(t, "second", got.Value1)
+
+	// the same holds for batch writes
+	intercepted.afterPublish = func() {
+		intercepted.afterPublish = func() {}
+		require.Nil(t, cutB.SetMany(ctx, map[string]demoEntity{"key1": {Value1: "third"}}, time.Hour))
+	}
+	require.Nil(t, cutA.SetMany(ctx, map[string]demoEntity{"key1": {Value1: "fourth"}}, time.Hour))
+	got, err = cutA.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.Equal(t, "third", got.Value1)
+}
+
+// partiallyFailingCache fails to remove the key failing, removing all other keys
+type partiallyFailingCache[Entity any] struct {
+	Cache[Entity]
+	failing string
+}
+
+func (c *partiallyFailingCache[Entity]) RemoveMany(ctx context.Context, keys []string) error {
+	errs := make(map[string]error)
+	for _, key := range keys {
+		if key == c.failing {
+			errs[key] = errors.New("removal failed")
+		} else if err := c.Cache.Remove(ctx, key); err != nil {
+			errs[key] = err
+		}
+	}
+	return batchError(errs)
+}
+
+func TestTieredCacheRemoveManyAnnouncesRemovedKeysOfFailingBatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	remote := NewMemoryCache[demoEntity]()
+	invalidator := NewMemoryInvalidator()
+	localB := NewMemoryCache[demoEntity]()
+	cutA := NewTieredCache(ctx, NewMemoryCache[demoEntity](), &partiallyFailingCache[demoEntity]{Cache: remote, failing: "key2"}, invalidator, nil)
+	cutB := NewTieredCache(ctx, localB, remote, invalidator, nil)
+	waitForSubscribers(t, invalidator, 2)
+
+	require.Nil(t, cutA.SetMany(ctx, map[string]demoEntity{"key1": {Value1: "1"}, "key2": {Value1: "2"}}, time.Hour))
+	for _, key := range []string{"key1", "key2"} {
+		_, err := cutB.Get(ctx, key)
+		require.Nil(t, err)
+	}
+
+	err := cutA.RemoveMany(ctx, []string{"key1", "key2"})
+	var batchErr ErrBatch
+	require.ErrorAs(t, err, &batchErr)
+	require.Contains(t, batchErr.KeyErrors(), "key2")
+
+	keys, err := localB.Keys(ctx)
+	require.Nil(t, err)
+	require.Equal(t, []string{"key2"}, keys)
+}
+
+func waitForSubscribers(t *testing.T, invalidator Invalidator, count int) {
+	require.Eventually(t, func() bool {
+		subscribers := invalidator.(*memoryInvalidator).subscribers
+		subscribers.mu.RLock()
+		defer subscribers.mu.RUnlock()
+		return len(subscribers.subscribers) == count
+	}, time.Second, time.Millisecond)
+}
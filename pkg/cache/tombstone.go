@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Tombstone is the value stored for keys that are known to be missing.
+type Tombstone struct {
+}
+
+// NegativeCache remembers keys that are known to be missing for a retention independent of the
+// retention of the values they would refer to.
+type NegativeCache struct {
+	cache     Cache[Tombstone]
+	retention time.Duration
+}
+
+func NewNegativeCache(
+	cache Cache[Tombstone],
+	retention time.Duration,
+) *NegativeCache {
+	return &NegativeCache{
+		cache:     cache,
+		retention: retention,
+	}
+}
+
+func (c *NegativeCache) MarkMissing(
+	ctx context.Context,
+	key string,
+) error {
+	return c.cache.Set(ctx, key, Tombstone{}, c.retention)
+}
+
+func (c *NegativeCache) IsMissing(
+	ctx context.Context,
+	key string,
+) (bool, error) {
+	tombstone, err := c.cache.Get(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	return tombstone != nil, nil
+}
+
+func (c *NegativeCache) Forget(
+	ctx context.Context,
+	key string,
+) error {
+	return c.cache.Remove(ctx, key)
+}
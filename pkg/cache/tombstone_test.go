@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func TestNegativeCache(t *testing.T) {
+	ctx := context.TODO()
+	cut := NewNegativeCache(NewMemoryCache[Tombstone](), 20*time.Millisecond)
+
+	missing, err := cut.IsMissing(ctx, "key1")
+	require.Nil(t, err)
+	require.False(t, missing)
+
+	require.Nil(t, cut.MarkMissing(ctx, "key1"))
+	missing, err = cut.IsMissing(ctx, "key1")
+	require.Nil(t, err)
+	require.True(t, missing)
+
+	require.Nil(t, cut.Forget(ctx, "key1"))
+	missing, err = cut.IsMissing(ctx, "key1")
+	require.Nil(t, err)
+	require.False(t, missing)
+
+	require.Nil(t, cut.MarkMissing(ctx, "key1"))
+	time.Sleep(30 * time.Millisecond)
+	missing, err = cut.IsMissing(ctx, "key1")
+	require.Nil(t, err)
+	require.False(t, missing)
+}
@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+type TypedMemoryCacheConfig[Entity any] struct {
+	// JanitorInterval is the period in which expired entries are removed in the background,
+	// a non-positive value disables the janitor and expired entries are only removed on access
+	JanitorInterval time.Duration
+	// MaxEntries bounds the number of entries, a non-positive value means unbounded
+	MaxEntries int
+	// MaxBytes bounds the summed size of all keys and values as reported by Size, a non-positive value means unbounded
+	MaxBytes int64
+	// EvictionPolicy creates the policy of a bounded cache, defaults to NewLRUEvictionPolicy
+	EvictionPolicy func() EvictionPolicy
+	// OnEviction is called outside the cache lock for every entry dropped due to capacity or expiry
+	OnEviction func(key string, reason EvictionReason)
+	// WatchBufferSize is the number of events buffered per watcher before it is dropped
+	WatchBufferSize int
+	// SlidingRetention, if positive, resets the retention of every entry read by Get or GetMany to the given
+	// duration, so that entries only expire after a period of inactivity
+	SlidingRetention time.Duration
+	// Clone deep copies values when they are stored and returned, so that neither the caller nor other readers
+	// can modify stored values. Without it values are copied by assignment only, which shares the content of
+	// pointers, slices and maps. Callers must then treat such content as immutable and replace values by
+	// calling Set with a modified copy instead of modifying them in place.
+	Clone func(value Entity) Entity
+	// Size estimates the size of a value in bytes for MaxBytes, without it only the keys are accounted for
+	Size func(value Entity) int64
+}
+
+func CreateDefaultTypedMemoryCacheConfig[Entity any]() TypedMemoryCacheConfig[Entity] {
+	return TypedMemoryCacheConfig[Entity]{
+		JanitorInterval: 1 * time.Minute,
+		WatchBufferSize: 100,
+	}
+}
+
+// NewTypedMemoryCache creates a memory cache that keeps values as they are instead of encoding them,
+// which avoids the serialisation costs of NewMemoryCache. Values are shared as described for
+// TypedMemoryCacheConfig.Clone.
+func NewTypedMemoryCache[Entity any]() Cache[Entity] {
+	return newMemoryCache[Entity](MemoryCacheConfig{}, typedValues[Entity]{})
+}
+
+// NewTypedMemoryCacheWithConfig creates a typed memory cache whose janitor runs until ctx is done.
+func NewTypedMemoryCacheWithConfig[Entity any](
+	ctx context.Context,
+	config *TypedMemoryCacheConfig[Entity],
+) Cache[Entity] {
+	var vConfig TypedMemoryCacheConfig[Entity]
+	if config != nil {
+		vConfig = *config
+	} else {
+		vConfig = CreateDefaultTypedMemoryCacheConfig[Entity]()
+	}
+
+	return startMemoryCache[Entity](ctx, MemoryCacheConfig{
+		JanitorInterval:  vConfig.JanitorInterval,
+		MaxEntries:       vConfig.MaxEntries,
+		MaxBytes:         vConfig.MaxBytes,
+		EvictionPolicy:   vConfig.EvictionPolicy,
+		OnEviction:       vConfig.OnEviction,
+		WatchBufferSize:  vConfig.WatchBufferSize,
+		SlidingRetention: vConfig.SlidingRetention,
+	}, typedValues[Entity]{
+		clone: vConfig.Clone,
+		size:  vConfig.Size,
+	})
+}
+
+// typedValues stores the values themselves
+type typedValues[Entity any] struct {
+	clone func(value Entity) Entity
+	size  func(value Entity) int64
+}
+
+func (v typedValues[Entity]) store(entry *memoryEntry[Entity], value Entity) error {
+	if v.clone != nil {
+		value = v.clone(value)
+	}
+	entry.value = value
+	if v.size != nil {
+		entry.valueSize = v.size(value)
+	}
+	return nil
+}
+
+func (v typedValues[Entity]) load(entry *memoryEntry[Entity]) (*Entity, error) {
+	value := entry.value
+	if v.clone != nil {
+		value = v.clone(value)
+	}
+	return &value, nil
+}
@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"fmt"
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func cloneDemoEntity(value demoEntity) demoEntity {
+	if value.Value2 != nil {
+		value.Value2 = p(*value.Value2)
+	}
+	if value.Value3 != nil {
+		value.Value3 = p(maps.Clone(*value.Value3))
+	}
+	return value
+}
+
+func TestTypedMemoryCacheClone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	cut := NewTypedMemoryCacheWithConfig[demoEntity](ctx, &TypedMemoryCacheConfig[demoEntity]{
+		Clone: cloneDemoEntity,
+	})
+
+	e1 := demoEntity{Value1: "first", Value2: p("second"), Value3: &map[string]string{"key": "third"}}
+	require.Nil(t, cut.Set(ctx, "key1", e1, 0))
+	*e1.Value2 = "modified"
+	(*e1.Value3)["key"] = "modified"
+
+	got, err := cut.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.Equal(t, "second", *got.Value2)
+	require.Equal(t, "third", (*got.Value3)["key"])
+	got.Value1 = "modified"
+	*got.Value2 = "modified"
+
+	entries, err := cut.Entries(ctx)
+	require.Nil(t, err)
+	require.Equal(t, "first", entries["key1"].Value1)
+	require.Equal(t, "second", *entries["key1"].Value2)
+}
+
+func TestTypedMemoryCacheWithoutClone(t *testing.T) {
+	ctx := context.TODO()
+	cut := NewTypedMemoryCache[demoEntity]()
+
+	e1 := demoEntity{Value1: "first", Value2: p("second")}
+	require.Nil(t, cut.Set(ctx, "key1", e1, time.Hour))
+	e1.Value1 = "modified"
+
+	got, err := cut.Get(ctx, "key1")
+	require.Nil(t, err)
+	require.Equal(t, "first", got.Value1)
+	// pointers are shared without a clone function
+	require.Same(t, e1.Value2, got.Value2)
+
+	swapped, err := cut.CompareAndSwap(ctx, "key1", demoEntity{Value1: "first", Value2: p("second")},
+		demoEntity{Value1: "swapped"}, 0)
+	require.Nil(t, err)
+	require.True(t, swapped)
+
+	_, metadata, err := cut.(MetadataCache[demoEntity]).GetWithMetadata(ctx, "key1")
+	require.Nil(t, err)
+	require.Equal(t, int64(2), metadata.Version)
+
+	values, err := cut.WithNamespace("tenant").GetMany(ctx, []string{"key1"})
+	require.Nil(t, err)
+	require.Empty(t, values)
+}
+
+func TestTypedMemoryCacheMaxBytes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	cut := NewTypedMemoryCacheWithConfig[string](ctx, &TypedMemoryCacheConfig[string]{
+		MaxBytes: 10,
+		Size: func(value string) int64 {
+			return int64(len(value))
+		},
+	})
+
+	require.Nil(t, cut.Set(ctx, "a", "1234", 0))
+	require.Nil(t, cut.Set(ctx, "b", "1234", 0))
+	require.Nil(t, cut.Set(ctx, "c", "1234", 0))
+	keys, err := cut.Keys(ctx)
+	require.Nil(t, err)
+	require.ElementsMatch(t, []string{"b", "c"}, keys)
+
+	err = cut.Set(ctx, "d", "12345678901", 0)
+	require.ErrorAs(t, err, &ErrEntryTooLarge{})
+}
+
+func BenchmarkMemoryCaches(b *testing.B) {
+	ctx := context.TODO()
+	benchmarkCases := []struct {
+		name   string
+		create func() Cache[demoEntity]
+	}{
+		{name: "json", create: NewMemoryCache[demoEntity]},
+		{name: "typed", create: NewTypedMemoryCache[demoEntity]},
+		{name: "typed_clone", create: func() Cache[demoEntity] {
+			return NewTypedMemoryCacheWithConfig[demoEntity](ctx, &TypedMemoryCacheConfig[demoEntity]{
+				Clone: cloneDemoEntity,
+			})
+		}},
+	}
+	value := demoEntity{
+		Value1: "first",
+		Value2: p("second"),
+		Value3: &map[string]string{"a": "1", "b": "2", "c": "3"},
+	}
+
+	for _, bc := range benchmarkCases {
+		b.Run(bc.name+"/get", func(b *testing.B) {
+			cut := bc.create()
+			require.Nil(b, cut.Set(ctx, "key", value, 0))
+			for b.Loop() {
+				if _, err := cut.Get(ctx, "key"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(bc.name+"/set", func(b *testing.B) {
+			cut := bc.create()
+			for b.Loop() {
+				if err := cut.Set(ctx, "key", value, 0); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(bc.name+"/entries", func(b *testing.B) {
+			cut := bc.create()
+			for i := range 100 {
+				require.Nil(b, cut.Set(ctx, fmt.Sprintf("key%d", i), value, 0))
+			}
+			for b.Loop() {
+				if _, err := cut.Entries(ctx); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"context"
+	"sync"
+)
+
+type EventType int64
+
+const (
+	EventTypeAdded EventType = iota
+	EventTypeUpdated
+	EventTypeRemoved
+)
+
+type Event[Entity any] struct {
+	Type EventType
+	Key  string
+	// Value is nil for removals
+	Value *Entity
+}
+
+// WatchableCache is implemented by caches that can report changes, including those made by other replicas.
+// The caches returned by NewMemoryCache, NewTypedMemoryCache and NewRedisCache can be asserted to it.
+type WatchableCache[Entity any] interface {
+	Cache[Entity]
+
+	// Watch streams change events until ctx is done. Every watcher has its own buffer, a watcher
+	// that falls behind by more than the buffer size is dropped by closing its channel instead of
+	// blocking writers. A consumer observing a closed channel while ctx is still active has
+	// missed events and has to re-read the cache before watching again.
+	Watch(
+		ctx context.Context,
+	) (<-chan Event[Entity], error)
+}
+
+type watcher[Entity any] struct {
+	mu     sync.Mutex
+	events chan Event[Entity]
+	closed bool
+}
+
+func newWatcher[Entity any](bufferSize int) *watcher[Entity] {
+	return &watcher[Entity]{
+		events: make(chan Event[Entity], bufferSize),
+	}
+}
+
+// send delivers event without blocking, a full buffer closes the watcher and reports false
+func (w *watcher[Entity]) send(event Event[Entity]) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return false
+	}
+	select {
+	case w.events <- event:
+		return true
+	default:
+		w.closed = true
+		close(w.events)
+		return false
+	}
+}
+
+func (w *watcher[Entity]) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.closed {
+		w.closed = true
+		close(w.events)
+	}
+}
+
+// watchHub fans out events to all watchers within the current process
+type watchHub[Entity any] struct {
+	mu         sync.RWMutex
+	watchers   map[*watcher[Entity]]bool
+	bufferSize int
+}
+
+func newWatchHub[Entity any](bufferSize int) *watchHub[Entity] {
+	return &watchHub[Entity]{
+		watchers:   make(map[*watcher[Entity]]bool),
+		bufferSize: bufferSize,
+	}
+}
+
+func (h *watchHub[Entity]) watch(ctx context.Context) <-chan Event[Entity] {
+	w := newWatcher[Entity](h.bufferSize)
+	h.mu.Lock()
+	h.watchers[w] = true
+	h.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		h.mu.Lock()
+		delete(h.watchers, w)
+		h.mu.Unlock()
+		w.close()
+	}()
+	return w.events
+}
+
+func (h *watchHub[Entity]) hasWatchers() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.watchers) > 0
+}
+
+// publish delivers events to all watchers and removes those dropped for overflow, so that writes
+// stop building events for them
+func (h *watchHub[Entity]) publish(events ...Event[Entity]) {
+	dropped := make([]*watcher[Entity], 0)
+	h.mu.RLock()
+	for w := range h.watchers {
+		for _, event := range events {
+			if !w.send(event) {
+				dropped = append(dropped, w)
+				break
+			}
+		}
+	}
+	h.mu.RUnlock()
+	if len(dropped) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, w := range dropped {
+		delete(h.watchers, w)
+	}
+}
@@ -22,6 +22,19 @@ type CachedRepository[BaseEntity any, ProcessedEntity any, ChangeContext any] st
 	processor  Processor[BaseEntity, ProcessedEntity]
 	locker     locker.Locker
 	hooks      Hooks[ProcessedEntity]
+	config     Config
+}
+
+type Config struct {
+	// NegativeCache remembers names missing in the repository so that reads of them are answered
+	// without reconciliation until the tombstone expires, nil disables negative caching
+	NegativeCache *cache.NegativeCache
+}
+
+func CreateDefaultConfig() Config {
+	return Config{
+		NegativeCache: nil,
+	}
 }
 
 func NewCachedRepository[BaseEntity any, ProcessedEntity any, ChangeContext any](
@@ -32,6 +45,25 @@ func NewCachedRepository[BaseEntity any, ProcessedEntity any, ChangeContext any]
 	locker locker.Locker,
 	hooks Hooks[ProcessedEntity],
 ) *CachedRepository[BaseEntity, ProcessedEntity, ChangeContext] {
+	return NewCachedRepositoryWithConfig(key, repository, cache, processor, locker, hooks, nil)
+}
+
+func NewCachedRepositoryWithConfig[BaseEntity any, ProcessedEntity any, ChangeContext any](
+	key string,
+	repository Repository[BaseEntity, ChangeContext],
+	cache cache.Cache[ProcessedEntity],
+	processor Processor[BaseEntity, ProcessedEntity],
+	locker locker.Locker,
+	hooks Hooks[ProcessedEntity],
+	config *Config,
+) *CachedRepository[BaseEntity, ProcessedEntity, ChangeContext] {
+	var vConfig Config
+	if config != nil {
+		vConfig = *config
+	} else {
+		vConfig = CreateDefaultConfig()
+	}
+
 	if reflect.ValueOf(locker).IsNil() {
 		locker = nil
 	}
@@ -46,6 +78,7 @@ func NewCachedRepository[BaseEntity any, ProcessedEntity any, ChangeContext any]
 		processor:  processor,
 		locker:     locker,
 		hooks:      hooks,
+		config:     vConfig,
 	}
 }
 
@@ -157,6 +190,10 @@ func (c *CachedRepository[BaseEntity, ProcessedEntity, ChangeContext]) Read(
 ) (ProcessedEntity, string, error) {
 	cachedEntity, err := c.cache.Get(ctx, name)
 	if err != nil || cachedEntity == nil {
+		// the tombstone is only trusted if the cache could be read, failing reads fall back to reconciliation
+		if err == nil && c.isKnownMissing(ctx, name) {
+			return *new(ProcessedEntity), "", NewErrRepositoryEntityNotFound(name)
+		}
 		if err = c.Reconcile(ctx, name); err != nil {
 			return *new(ProcessedEntity), "", err
 		}
@@ -165,6 +202,7 @@ func (c *CachedRepository[BaseEntity, ProcessedEntity, ChangeContext]) Read(
 			return *new(ProcessedEntity), "", err
 		}
 		if cachedEntity == nil {
+			c.markMissing(ctx, name)
 			return *new(ProcessedEntity), "", NewErrRepositoryEntityNotFound(name)
 		}
 	}
@@ -276,6 +314,9 @@ func (c *CachedRepository[BaseEntity, ProcessedEntity, ChangeContext]) performCa
 	entity *ProcessedEntity,
 	cause CacheActionCause,
 ) error {
+	if entity != nil {
+		c.forgetMissing(ctx, name)
+	}
 	cachedEntity, err := c.cache.Get(ctx, name)
 	if err != nil {
 		return err
@@ -283,10 +324,15 @@ func (c *CachedRepository[BaseEntity, ProcessedEntity, ChangeContext]) performCa
 	if entity == nil && cachedEntity == nil {
 		return nil
 	} else if entity == nil && cachedEntity != nil {
-		if err = c.cache.Remove(ctx, name); err != nil {
-			aulogging.Logger.Ctx(ctx).Warn().WithErr(err).
+		removed, innerErr := c.cache.RemoveIfEquals(ctx, name, *cachedEntity)
+		if innerErr != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(innerErr).
 				Printf("failed to remove %s entity '%s' from cache, cache will be out of date until reconciliation", c.key, name)
-			return err
+			return innerErr
+		}
+		if !removed {
+			c.logConcurrentCacheModification(ctx, name)
+			return nil
 		}
 		aulogging.Logger.Ctx(ctx).Info().Printf("successfully removed %s entity '%s' from cache", c.key, name)
 		if c.hooks != nil {
@@ -297,9 +343,12 @@ func (c *CachedRepository[BaseEntity, ProcessedEntity, ChangeContext]) performCa
 			}
 		}
 	} else if entity != nil && cachedEntity == nil {
-		if err = c.cache.Set(ctx, name, *entity, 0); err != nil {
-			aulogging.Logger.Ctx(ctx).Warn().WithErr(err).
+		if stored, innerErr := c.cache.SetIfAbsent(ctx, name, *entity, 0); innerErr != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(innerErr).
 				Printf("failed to cache %s entity %s", c.key, name)
+		} else if !stored {
+			c.logConcurrentCacheModification(ctx, name)
+			return nil
 		}
 		aulogging.Logger.Ctx(ctx).Info().Printf("successfully added %s entity '%s' to cache", c.key, name)
 		if c.hooks != nil {
@@ -309,9 +358,12 @@ func (c *CachedRepository[BaseEntity, ProcessedEntity, ChangeContext]) performCa
 			}
 		}
 	} else if !defaultCompareEqual(*entity, *cachedEntity) {
-		if err = c.cache.Set(ctx, name, *entity, 0); err != nil {
-			aulogging.Logger.Ctx(ctx).Warn().WithErr(err).
+		if swapped, innerErr := c.cache.CompareAndSwap(ctx, name, *cachedEntity, *entity, 0); innerErr != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(innerErr).
 				Printf("failed to update %s entity %s in cache", c.key, name)
+		} else if !swapped {
+			c.logConcurrentCacheModification(ctx, name)
+			return nil
 		}
 		aulogging.Logger.Ctx(ctx).Info().Printf("successfully updated %s entity '%s' in cache", c.key, name)
 		if c.hooks != nil {
@@ -324,6 +376,56 @@ func (c *CachedRepository[BaseEntity, ProcessedEntity, ChangeContext]) performCa
 	return nil
 }
 
+func (c *CachedRepository[BaseEntity, ProcessedEntity, ChangeContext]) logConcurrentCacheModification(
+	ctx context.Context,
+	name string,
+) {
+	aulogging.Logger.Ctx(ctx).Info().
+		Printf("%s entity '%s' was modified in cache concurrently, skipping cache action until next reconciliation", c.key, name)
+}
+
+func (c *CachedRepository[BaseEntity, ProcessedEntity, ChangeContext]) isKnownMissing(
+	ctx context.Context,
+	name string,
+) bool {
+	if c.config.NegativeCache == nil {
+		return false
+	}
+	missing, err := c.config.NegativeCache.IsMissing(ctx, name)
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).
+			Printf("failed to look up tombstone of %s entity '%s', falling back to reconciliation", c.key, name)
+		return false
+	}
+	return missing
+}
+
+func (c *CachedRepository[BaseEntity, ProcessedEntity, ChangeContext]) markMissing(
+	ctx context.Context,
+	name string,
+) {
+	if c.config.NegativeCache == nil {
+		return
+	}
+	if err := c.config.NegativeCache.MarkMissing(ctx, name); err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).
+			Printf("failed to store tombstone of %s entity '%s'", c.key, name)
+	}
+}
+
+func (c *CachedRepository[BaseEntity, ProcessedEntity, ChangeContext]) forgetMissing(
+	ctx context.Context,
+	name string,
+) {
+	if c.config.NegativeCache == nil {
+		return
+	}
+	if err := c.config.NegativeCache.Forget(ctx, name); err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).
+			Printf("failed to remove tombstone of %s entity '%s', reads will report it missing until the tombstone expires", c.key, name)
+	}
+}
+
 func (c *CachedRepository[BaseEntity, ProcessedEntity, ChangeContext]) synchronised(
 	ctx context.Context,
 	callback func(context.Context) error,
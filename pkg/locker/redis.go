@@ -14,13 +14,42 @@ func NewRedisLocker(
 	redisURL string,
 	redisPassword string,
 ) (Locker, error) {
-	locker, err := rueidislock.NewLocker(rueidislock.LockerOption{
-		ClientOption: rueidis.ClientOption{
-			InitAddress: []string{redisURL},
-			Password:    redisPassword},
+	return NewRedisLockerWithOptions(rueidis.ClientOption{
+		InitAddress: []string{redisURL},
+		Password:    redisPassword,
+	})
+}
+
+// NewRedisLockerWithOptions creates a locker with its own connection pool built from clientOption,
+// allowing TLS, ACL users, database selection, Sentinel or Cluster setups.
+func NewRedisLockerWithOptions(
+	clientOption rueidis.ClientOption,
+) (Locker, error) {
+	return newRedisLocker(rueidislock.LockerOption{
+		ClientOption: clientOption,
+		KeyMajority:  2,
+	})
+}
+
+// NewRedisLockerFromClient creates a locker on top of an existing client so that its connection pool can be
+// shared. As the client's invalidation callbacks cannot be registered afterwards, waiting for a held lock
+// falls back to retrying periodically instead of being notified on release.
+func NewRedisLockerFromClient(
+	client rueidis.Client,
+) (Locker, error) {
+	return newRedisLocker(rueidislock.LockerOption{
+		ClientOption: rueidis.ClientOption{DisableCache: true},
+		ClientBuilder: func(_ rueidis.ClientOption) (rueidis.Client, error) {
+			return client, nil
+		},
 		KeyMajority: 2,
 	})
+}
 
+func newRedisLocker(
+	option rueidislock.LockerOption,
+) (Locker, error) {
+	locker, err := rueidislock.NewLocker(option)
 	if err != nil {
 		return nil, err
 	}